@@ -16,7 +16,10 @@ limitations under the License.
 
 package v1
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
@@ -28,33 +31,275 @@ type WorkloadScheduleSpec struct {
 
 	Selector  WorkloadSelector       `json:"selector,omitempty"`
 	Schedules []WorkloadScheduleUnit `json:"schedules,omitempty"`
+	// HPAPolicy decides how workloads targeted by a HorizontalPodAutoscaler are scheduled, Replicas (default) sets the
+	// replicas of the workload, AdjustBounds sets the minReplicas of the HorizontalPodAutoscaler to desired instead and
+	// restores its original bounds once no schedule applies.
+	// +kubebuilder:validation:Enum=Replicas;AdjustBounds
+	HPAPolicy HPAPolicy `json:"hpaPolicy,omitempty"`
+	// DefaultDesired applies to the selected workloads whenever none of the schedules is active.
+	// +kubebuilder:validation:Minimum=0
+	DefaultDesired *int32 `json:"defaultDesired,omitempty"`
+	// MinReplicas bounds the replicas the selected workloads are scheduled to, along with the min-replicas annotation
+	// of the workload and the MIN_REPLICAS env of the operator, the highest minimum applies.
+	// +kubebuilder:validation:Minimum=0
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas bounds the replicas the selected workloads are scheduled to, along with the max-replicas annotation
+	// of the workload and the MAX_REPLICAS env of the operator, the lowest maximum applies.
+	// +kubebuilder:validation:Minimum=0
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// QuotaProfiles are named hard limits selected resourcequotas switch to with the quotaProfile of a schedule.
+	QuotaProfiles map[string]core.ResourceList `json:"quotaProfiles,omitempty"`
+	// Dependencies order the changes of the selected workloads within a namespace, a workload is scaled up once the workloads
+	// it depends on are ready and the workloads it depends on are scaled down once it is scaled down.
+	Dependencies []WorkloadDependency `json:"dependencies,omitempty"`
 }
 
+type HPAPolicy string
+
+const (
+	HPAPolicyReplicas     HPAPolicy = "Replicas"
+	HPAPolicyAdjustBounds HPAPolicy = "AdjustBounds"
+)
+
 type WorkloadScheduleUnit struct {
 	Schedule string `json:"schedule,omitempty"`
-	Desired  int32  `json:"desired,omitempty"`
+	// Action of the schedule, Scale (default) schedules the selected workloads, Restart restarts the selected deployments
+	// and statefulsets once per occurrence of the schedule and Patch applies patch to the selected objects, neither changes
+	// their replicas.
+	// +kubebuilder:validation:Enum=Scale;Restart;Patch
+	Action  Action `json:"action,omitempty"`
+	Desired int32  `json:"desired,omitempty"`
+	// Percentage computes desired as a percentage of the baseline replicas of each workload instead of Desired.
+	Percentage *DesiredPercentage `json:"percentage,omitempty"`
+	// DesiredExpression is a CEL expression computing desired per workload instead of Desired or Percentage, the workload is
+	// exposed as `workload`, its current and baseline replicas as `current` and `baseline`.
+	DesiredExpression string `json:"desiredExpression,omitempty"`
+	// Ramp moves desired linearly between two replica levels while the schedule applies instead of Desired.
+	Ramp *Ramp `json:"ramp,omitempty"`
+	// HPA overrides further bounds of the HorizontalPodAutoscaler while the schedule applies, used with the AdjustBounds hpaPolicy.
+	HPA *HPAOverride `json:"hpa,omitempty"`
+	// Knative sets the scale bounds of knative services while the schedule applies, min-scale defaults to desired.
+	Knative *KnativeScale `json:"knative,omitempty"`
+	// Follow computes desired as a ratio of the replicas of another workload instead of Desired, Percentage, DesiredExpression or Ramp.
+	Follow *Follow `json:"follow,omitempty"`
+	// Resources sets the requests and limits of the containers of selected deployments and statefulsets while the schedule
+	// applies, the original requests and limits are restored once no schedule with resources applies.
+	Resources *ResourceProfile `json:"resources,omitempty"`
+	// QuotaProfile is the name of the quotaProfiles entry whose hard limits replace those of selected resourcequotas while
+	// the schedule applies, the original hard limits are restored once no schedule applies.
+	QuotaProfile string `json:"quotaProfile,omitempty"`
+	// Patch is applied to the selected objects with the Patch action.
+	Patch *SchedulePatch `json:"patch,omitempty"`
+	// Snapshot takes a VolumeSnapshot of each persistentvolumeclaim of the selected statefulsets before they are scaled to 0,
+	// a statefulset is only scaled to 0 once all its snapshots are ready to use.
+	Snapshot *VolumeSnapshotPolicy `json:"snapshot,omitempty"`
+	// Verify checks the selected deployments and statefulsets report ready replicas after they are scaled up.
+	Verify *ReadinessVerification `json:"verify,omitempty"`
+}
+
+type ReadinessVerification struct {
+	// TimeoutSeconds is the time a scaled up workload has to report desired ready replicas.
+	// +kubebuilder:default=300
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// Rollback scales a workload not ready in time back to its replicas before the scale up, until the desired replicas change.
+	Rollback bool `json:"rollback,omitempty"`
+}
+
+type WorkloadDependency struct {
+	// Workload depends on the workloads of DependsOn in its namespace.
+	Workload WorkloadReference `json:"workload"`
+	// +kubebuilder:validation:MinItems=1
+	DependsOn []WorkloadReference `json:"dependsOn"`
+}
+
+type WorkloadReference struct {
+	// Kind of the workload, deployment (default) or statefulset.
+	// +kubebuilder:validation:Enum=deployment;statefulset
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name"`
+}
+
+type VolumeSnapshotPolicy struct {
+	// VolumeSnapshotClassName of the snapshots, the default class of the cluster when empty.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+	// Retention is the number of snapshots kept per persistentvolumeclaim, older snapshots taken by the operator are deleted.
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	Retention int32 `json:"retention,omitempty"`
+}
+
+type ResourceProfile struct {
+	// Percentage of the original requests and limits of every container, rounded up.
+	// +kubebuilder:validation:Minimum=1
+	Percentage *int32 `json:"percentage,omitempty"`
+	// Containers sets requests and limits of containers by name, over the percentage.
+	Containers []ContainerResources `json:"containers,omitempty"`
+}
+
+type ContainerResources struct {
+	Name     string            `json:"name"`
+	Requests core.ResourceList `json:"requests,omitempty"`
+	Limits   core.ResourceList `json:"limits,omitempty"`
+}
+
+type Action string
+
+const (
+	ActionScale   Action = "Scale"
+	ActionRestart Action = "Restart"
+	ActionPatch   Action = "Patch"
+)
+
+type SchedulePatch struct {
+	// Type of the patch, Merge (default) for a JSON merge patch or JSON for a JSON patch.
+	// +kubebuilder:validation:Enum=Merge;JSON
+	Type PatchType `json:"type,omitempty"`
+	// Patch is the patch document in JSON or YAML, applied while the schedule applies and reverted once no schedule with
+	// the Patch action applies to the object anymore.
+	Patch string `json:"patch"`
+}
+
+type PatchType string
+
+const (
+	PatchTypeMerge PatchType = "Merge"
+	PatchTypeJSON  PatchType = "JSON"
+)
+
+type Follow struct {
+	// Kind of the followed workload, deployment when empty.
+	Kind string `json:"kind,omitempty"`
+	// Namespace of the followed workload, the namespace of the following workload when empty.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Ratio of the followed replicas desired is set to, rounded up, 1 when empty e.g. 0.5.
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Ratio string `json:"ratio,omitempty"`
+	// Replicas of the followed workload the ratio applies to, Scheduled (default) uses the replicas it is scheduled to in the
+	// same run and falls back to Current when no workload schedule applies to it.
+	// +kubebuilder:validation:Enum=Scheduled;Current
+	Replicas FollowReplicas `json:"replicas,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	Min *int32 `json:"min,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	Max *int32 `json:"max,omitempty"`
+}
+
+type FollowReplicas string
+
+const (
+	FollowReplicasScheduled FollowReplicas = "Scheduled"
+	FollowReplicasCurrent   FollowReplicas = "Current"
+)
+
+type KnativeScale struct {
+	// +kubebuilder:validation:Minimum=0
+	MinScale *int32 `json:"minScale,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	MaxScale *int32 `json:"maxScale,omitempty"`
+}
+
+type Ramp struct {
+	// From is desired until Start.
+	// +kubebuilder:validation:Minimum=0
+	From int32 `json:"from"`
+	// To is desired from End.
+	// +kubebuilder:validation:Minimum=0
+	To int32 `json:"to"`
+	// Start is when desired starts to move from From to To, on the day of the run when it sets no date.
+	Start TimeUnit `json:"start"`
+	// End is when desired reaches To, on the day of the run when it sets no date. End needs to be after Start, a ramp
+	// crossing midnight is rejected unless Start and End set their dates.
+	End TimeUnit `json:"end"`
+}
+
+type DesiredPercentage struct {
+	// Value is the percentage of the baseline replicas, the baseline is the replica count of the workload before the operator first changed it.
+	// +kubebuilder:validation:Minimum=0
+	Value int32 `json:"value"`
+	// Rounding of the computed replicas, Up (default), Down or Nearest.
+	// +kubebuilder:validation:Enum=Up;Down;Nearest
+	Rounding Rounding `json:"rounding,omitempty"`
+	// Minimum replicas the percentage may compute to.
+	// +kubebuilder:validation:Minimum=0
+	Minimum int32 `json:"minimum,omitempty"`
+}
+
+type Rounding string
+
+const (
+	RoundingUp      Rounding = "Up"
+	RoundingDown    Rounding = "Down"
+	RoundingNearest Rounding = "Nearest"
+)
+
+type HPAOverride struct {
+	// MaxReplicas replaces maxReplicas of the HorizontalPodAutoscaler, it is raised to desired when lower.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// TargetCPUUtilizationPercentage replaces the average cpu utilization target of the HorizontalPodAutoscaler.
+	// +kubebuilder:validation:Minimum=1
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
 }
 
 // WorkloadScheduleStatus defines the observed state of WorkloadSchedule
 type WorkloadScheduleStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// PausedJobs lists the jobs (namespace/name) currently paused by this workload schedule.
+	PausedJobs []string `json:"pausedJobs,omitempty"`
+	// WorkloadErrors lists the workloads (namespace/kind/name) this workload schedule failed to act upon in the last run with the error.
+	WorkloadErrors []string `json:"workloadErrors,omitempty"`
+	// ClampedWorkloads lists the workloads (namespace/kind/name) whose desired replicas were clamped to their bounds in the last run.
+	ClampedWorkloads []string `json:"clampedWorkloads,omitempty"`
+	// DeferredWorkloads lists the workloads (namespace/kind/name) whose change was deferred to a following run by the rollout limits
+	// of the operator or their dependencies.
+	DeferredWorkloads []string `json:"deferredWorkloads,omitempty"`
+	// DegradedWorkloads lists the workloads (namespace/kind/name) that did not report ready replicas within the timeout of verify after a scale up.
+	DegradedWorkloads []string `json:"degradedWorkloads,omitempty"`
+	// Conditions hold the Degraded condition of workload schedules verifying scale ups.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 type WorkloadSelector struct {
 	Namespaces []string          `json:"namespaces,omitempty"`
-	Kinds      []string          `json:"kinds,omitempty"` //TODO: make it enum
+	Kinds      []string          `json:"kinds,omitempty"` // deployment, statefulset or Kind.version.group of a kind exposing the scale subresource
 	Names      []string          `json:"names,omitempty"`
 	Labels     map[string]string `json:"labels,omitempty"`
+	// Filter is an optional CEL expression evaluated against each candidate workload (exposed as `workload`),
+	// only workloads for which it evaluates to true are acted upon.
+	Filter string `json:"filter,omitempty"`
 }
 
 type WorkloadScheduleData struct {
-	WorkloadScheduler string            `json:"workloadScheduler,omitempty"`
-	Namespace         string            `json:"namespace,omitempty"`
-	Kind              string            `json:"kind,omitempty"` //TODO: make it enum
-	Name              string            `json:"name,omitempty"`
-	Desired           int32             `json:"desired,omitempty"`
-	Labels            map[string]string `json:"labels,omitempty"`
+	WorkloadScheduler string                 `json:"workloadScheduler,omitempty"`
+	Namespace         string                 `json:"namespace,omitempty"`
+	Kind              string                 `json:"kind,omitempty"` //TODO: make it enum
+	Name              string                 `json:"name,omitempty"`
+	Desired           int32                  `json:"desired,omitempty"`
+	Percentage        *DesiredPercentage     `json:"percentage,omitempty"`
+	DesiredExpression string                 `json:"desiredExpression,omitempty"`
+	Labels            map[string]string      `json:"labels,omitempty"`
+	Filter            string                 `json:"filter,omitempty"`
+	HPAPolicy         HPAPolicy              `json:"hpaPolicy,omitempty"`
+	HPA               *HPAOverride           `json:"hpa,omitempty"`
+	Knative           *KnativeScale          `json:"knative,omitempty"`
+	Follow            *Follow                `json:"follow,omitempty"`
+	MinReplicas       *int32                 `json:"minReplicas,omitempty"`
+	MaxReplicas       *int32                 `json:"maxReplicas,omitempty"`
+	Resources         *ResourceProfile       `json:"resources,omitempty"`
+	Action            Action                 `json:"action,omitempty"`
+	Quota             core.ResourceList      `json:"quota,omitempty"`
+	Patch             *SchedulePatch         `json:"patch,omitempty"`
+	Snapshot          *VolumeSnapshotPolicy  `json:"snapshot,omitempty"`
+	Dependencies      []WorkloadDependency   `json:"dependencies,omitempty"`
+	Verify            *ReadinessVerification `json:"verify,omitempty"`
+	// Occurrence identifies the occurrence of the schedule (schedule@start) the data was built for.
+	Occurrence string `json:"occurrence,omitempty"`
+	// StartTime is the start of the occurrence of the schedule the data was built for, nil for the default desired state.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -22,9 +22,190 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	resource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerResources) DeepCopyInto(out *ContainerResources) {
+	*out = *in
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerResources.
+func (in *ContainerResources) DeepCopy() *ContainerResources {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerResources)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DesiredPercentage) DeepCopyInto(out *DesiredPercentage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesiredPercentage.
+func (in *DesiredPercentage) DeepCopy() *DesiredPercentage {
+	if in == nil {
+		return nil
+	}
+	out := new(DesiredPercentage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Follow) DeepCopyInto(out *Follow) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Follow.
+func (in *Follow) DeepCopy() *Follow {
+	if in == nil {
+		return nil
+	}
+	out := new(Follow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HPAOverride) DeepCopyInto(out *HPAOverride) {
+	*out = *in
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilizationPercentage != nil {
+		in, out := &in.TargetCPUUtilizationPercentage, &out.TargetCPUUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HPAOverride.
+func (in *HPAOverride) DeepCopy() *HPAOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(HPAOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KnativeScale) DeepCopyInto(out *KnativeScale) {
+	*out = *in
+	if in.MinScale != nil {
+		in, out := &in.MinScale, &out.MinScale
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxScale != nil {
+		in, out := &in.MaxScale, &out.MaxScale
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KnativeScale.
+func (in *KnativeScale) DeepCopy() *KnativeScale {
+	if in == nil {
+		return nil
+	}
+	out := new(KnativeScale)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Ramp) DeepCopyInto(out *Ramp) {
+	*out = *in
+	out.Start = in.Start
+	out.End = in.End
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ramp.
+func (in *Ramp) DeepCopy() *Ramp {
+	if in == nil {
+		return nil
+	}
+	out := new(Ramp)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadinessVerification) DeepCopyInto(out *ReadinessVerification) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessVerification.
+func (in *ReadinessVerification) DeepCopy() *ReadinessVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadinessVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceProfile) DeepCopyInto(out *ResourceProfile) {
+	*out = *in
+	if in.Percentage != nil {
+		in, out := &in.Percentage, &out.Percentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerResources, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceProfile.
+func (in *ResourceProfile) DeepCopy() *ResourceProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Schedule) DeepCopyInto(out *Schedule) {
 	*out = *in
@@ -84,6 +265,21 @@ func (in *ScheduleList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SchedulePatch) DeepCopyInto(out *SchedulePatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SchedulePatch.
+func (in *SchedulePatch) DeepCopy() *SchedulePatch {
+	if in == nil {
+		return nil
+	}
+	out := new(SchedulePatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScheduleSpec) DeepCopyInto(out *ScheduleSpec) {
 	*out = *in
@@ -158,13 +354,64 @@ func (in *TimeUnit) DeepCopy() *TimeUnit {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotPolicy) DeepCopyInto(out *VolumeSnapshotPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotPolicy.
+func (in *VolumeSnapshotPolicy) DeepCopy() *VolumeSnapshotPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadDependency) DeepCopyInto(out *WorkloadDependency) {
+	*out = *in
+	out.Workload = in.Workload
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]WorkloadReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadDependency.
+func (in *WorkloadDependency) DeepCopy() *WorkloadDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadReference) DeepCopyInto(out *WorkloadReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadReference.
+func (in *WorkloadReference) DeepCopy() *WorkloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadSchedule) DeepCopyInto(out *WorkloadSchedule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadSchedule.
@@ -277,6 +524,11 @@ func (in *WorkloadScheduleControllerStatus) DeepCopy() *WorkloadScheduleControll
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadScheduleData) DeepCopyInto(out *WorkloadScheduleData) {
 	*out = *in
+	if in.Percentage != nil {
+		in, out := &in.Percentage, &out.Percentage
+		*out = new(DesiredPercentage)
+		**out = **in
+	}
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
 		*out = make(map[string]string, len(*in))
@@ -284,6 +536,69 @@ func (in *WorkloadScheduleData) DeepCopyInto(out *WorkloadScheduleData) {
 			(*out)[key] = val
 		}
 	}
+	if in.HPA != nil {
+		in, out := &in.HPA, &out.HPA
+		*out = new(HPAOverride)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Knative != nil {
+		in, out := &in.Knative, &out.Knative
+		*out = new(KnativeScale)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Follow != nil {
+		in, out := &in.Follow, &out.Follow
+		*out = new(Follow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(ResourceProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Patch != nil {
+		in, out := &in.Patch, &out.Patch
+		*out = new(SchedulePatch)
+		**out = **in
+	}
+	if in.Snapshot != nil {
+		in, out := &in.Snapshot, &out.Snapshot
+		*out = new(VolumeSnapshotPolicy)
+		**out = **in
+	}
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make([]WorkloadDependency, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(ReadinessVerification)
+		**out = **in
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadScheduleData.
@@ -335,7 +650,48 @@ func (in *WorkloadScheduleSpec) DeepCopyInto(out *WorkloadScheduleSpec) {
 	if in.Schedules != nil {
 		in, out := &in.Schedules, &out.Schedules
 		*out = make([]WorkloadScheduleUnit, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultDesired != nil {
+		in, out := &in.DefaultDesired, &out.DefaultDesired
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.QuotaProfiles != nil {
+		in, out := &in.QuotaProfiles, &out.QuotaProfiles
+		*out = make(map[string]corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			var outVal map[corev1.ResourceName]resource.Quantity
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(corev1.ResourceList, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make([]WorkloadDependency, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -352,6 +708,38 @@ func (in *WorkloadScheduleSpec) DeepCopy() *WorkloadScheduleSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadScheduleStatus) DeepCopyInto(out *WorkloadScheduleStatus) {
 	*out = *in
+	if in.PausedJobs != nil {
+		in, out := &in.PausedJobs, &out.PausedJobs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WorkloadErrors != nil {
+		in, out := &in.WorkloadErrors, &out.WorkloadErrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClampedWorkloads != nil {
+		in, out := &in.ClampedWorkloads, &out.ClampedWorkloads
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeferredWorkloads != nil {
+		in, out := &in.DeferredWorkloads, &out.DeferredWorkloads
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DegradedWorkloads != nil {
+		in, out := &in.DegradedWorkloads, &out.DegradedWorkloads
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadScheduleStatus.
@@ -367,6 +755,51 @@ func (in *WorkloadScheduleStatus) DeepCopy() *WorkloadScheduleStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadScheduleUnit) DeepCopyInto(out *WorkloadScheduleUnit) {
 	*out = *in
+	if in.Percentage != nil {
+		in, out := &in.Percentage, &out.Percentage
+		*out = new(DesiredPercentage)
+		**out = **in
+	}
+	if in.Ramp != nil {
+		in, out := &in.Ramp, &out.Ramp
+		*out = new(Ramp)
+		**out = **in
+	}
+	if in.HPA != nil {
+		in, out := &in.HPA, &out.HPA
+		*out = new(HPAOverride)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Knative != nil {
+		in, out := &in.Knative, &out.Knative
+		*out = new(KnativeScale)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Follow != nil {
+		in, out := &in.Follow, &out.Follow
+		*out = new(Follow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(ResourceProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Patch != nil {
+		in, out := &in.Patch, &out.Patch
+		*out = new(SchedulePatch)
+		**out = **in
+	}
+	if in.Snapshot != nil {
+		in, out := &in.Snapshot, &out.Snapshot
+		*out = new(VolumeSnapshotPolicy)
+		**out = **in
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(ReadinessVerification)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadScheduleUnit.
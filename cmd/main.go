@@ -93,10 +93,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the same handler validates and runs workload schedules, so both know the kinds registered on it.
+	workloadScheduler := workloadScheduleHandler.New()
+	workloadScheduler.Recorder = mgr.GetEventRecorderFor("workload-scheduler-operator")
+	workloadScheduler.APIReader = mgr.GetAPIReader()
 	if err = (&controller.WorkloadScheduleReconciler{
 		Client:                   mgr.GetClient(),
 		Scheme:                   mgr.GetScheme(),
-		IWorkloadScheduleHandler: workloadScheduleHandler.New(),
+		IWorkloadScheduleHandler: workloadScheduler,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WorkloadSchedule")
 		os.Exit(1)
@@ -113,7 +117,7 @@ func main() {
 	workloadScheduleControllerReconciler := &controller.WorkloadScheduleControllerReconciler{
 		Client:                   mgr.GetClient(),
 		Scheme:                   mgr.GetScheme(),
-		IWorkloadScheduleHandler: workloadScheduleHandler.New(),
+		IWorkloadScheduleHandler: workloadScheduler,
 	}
 	if err = (workloadScheduleControllerReconciler).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WorkloadScheduleController")
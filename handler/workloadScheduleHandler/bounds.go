@@ -0,0 +1,107 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"fmt"
+	"math"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+)
+
+// replicaBound is a bound on the replicas of a workload and where it is defined.
+type replicaBound struct {
+	replicas int32
+	source   string
+}
+
+// getReplicaBounds returns the bounds of the workload from its annotations, the matched workload schedule and the operator envs,
+// the highest minimum and the lowest maximum apply.
+func (w *WorkloadScheduleHandler) getReplicaBounds(workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (*replicaBound, *replicaBound, error) {
+	var minBound, maxBound *replicaBound
+	bound := func(source string, minReplicas *int32, maxReplicas *int32) {
+		if minReplicas != nil && (minBound == nil || *minReplicas > minBound.replicas) {
+			minBound = &replicaBound{replicas: *minReplicas, source: source}
+		}
+		if maxReplicas != nil && (maxBound == nil || *maxReplicas < maxBound.replicas) {
+			maxBound = &replicaBound{replicas: *maxReplicas, source: source}
+		}
+	}
+
+	minReplicas, err := getAnnotationReplicas(workload, util.MinReplicasAnnotation)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxReplicas, err := getAnnotationReplicas(workload, util.MaxReplicasAnnotation)
+	if err != nil {
+		return nil, nil, err
+	}
+	bound("annotation", minReplicas, maxReplicas)
+
+	bound(fmt.Sprintf("workloadschedule %s", _workloadSchedule.WorkloadScheduler), _workloadSchedule.MinReplicas, _workloadSchedule.MaxReplicas)
+
+	if minReplicas, err = w.getEnvReplicas(config.MinReplicas); err != nil {
+		return nil, nil, err
+	}
+	if maxReplicas, err = w.getEnvReplicas(config.MaxReplicas); err != nil {
+		return nil, nil, err
+	}
+	bound("operator", minReplicas, maxReplicas)
+	return minBound, maxBound, nil
+}
+
+func getAnnotationReplicas(workload client.Object, annotation string) (*int32, error) {
+	value, ok := workload.GetAnnotations()[annotation]
+	if !ok {
+		return nil, nil
+	}
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || replicas < 0 {
+		return nil, fmt.Errorf("invalid %s annotation %s, expected a replica count", annotation, value)
+	}
+	_replicas := int32(replicas)
+	return &_replicas, nil
+}
+
+func (w *WorkloadScheduleHandler) getEnvReplicas(env string) (*int32, error) {
+	if _, ok := w.Config.Provider.LookUpEnv(env); !ok {
+		return nil, nil
+	}
+	replicas, err := w.Config.LookUpIntEnv(env)
+	if err != nil || replicas < 0 || replicas > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid %s env, expected a replica count", env)
+	}
+	_replicas := int32(replicas)
+	return &_replicas, nil
+}
+
+// scalesByReplicas reports whether replica bounds apply to the workloads of handler, they do not apply to workloads that
+// are only running or stopped nor to workloads whose state depends on more than replicas.
+func scalesByReplicas(handler WorkloadHandler) bool {
+	if toggler, ok := handler.(StateToggler); ok && toggler.TogglesState() {
+		return false
+	}
+	_, ok := handler.(WorkloadScheduleApplier)
+	return !ok
+}
+
+// clampReplicas keeps desired within the bounds of the workload, the minimum wins over a lower maximum so protected
+// workloads are never scheduled below it. It returns the reason when desired was clamped.
+func (w *WorkloadScheduleHandler) clampReplicas(workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData, desired int32) (int32, string, error) {
+	minBound, maxBound, err := w.getReplicaBounds(workload, _workloadSchedule)
+	if err != nil {
+		return 0, "", err
+	}
+	if maxBound != nil && desired > maxBound.replicas {
+		desired, reason := maxBound.replicas, fmt.Sprintf("desired %d clamped to max %d of %s", desired, maxBound.replicas, maxBound.source)
+		if minBound != nil && desired < minBound.replicas {
+			return minBound.replicas, fmt.Sprintf("%s, raised to min %d of %s", reason, minBound.replicas, minBound.source), nil
+		}
+		return desired, reason, nil
+	}
+	if minBound != nil && desired < minBound.replicas {
+		return minBound.replicas, fmt.Sprintf("desired %d clamped to min %d of %s", desired, minBound.replicas, minBound.source), nil
+	}
+	return desired, "", nil
+}
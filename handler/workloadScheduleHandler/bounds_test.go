@@ -0,0 +1,134 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestWorkloadScheduleHandler_clampReplicas(t *testing.T) {
+	two, five := int32(2), int32(5)
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		data        v1.WorkloadScheduleData
+		minReplicas string
+		maxReplicas string
+		desired     int32
+		want        int32
+		wantClamped string
+		wantErr     bool
+	}{
+		{name: "should keep desired within bounds.", data: v1.WorkloadScheduleData{WorkloadScheduler: "ws", MinReplicas: &two, MaxReplicas: &five}, desired: 3, want: 3},
+		{name: "should raise desired to min of annotation.", annotations: map[string]string{util.MinReplicasAnnotation: "3"}, data: v1.WorkloadScheduleData{WorkloadScheduler: "ws"},
+			desired: 0, want: 3, wantClamped: "desired 0 clamped to min 3 of annotation"},
+		{name: "should apply highest min.", annotations: map[string]string{util.MinReplicasAnnotation: "1"}, data: v1.WorkloadScheduleData{WorkloadScheduler: "ws", MinReplicas: &two},
+			desired: 0, want: 2, wantClamped: "desired 0 clamped to min 2 of workloadschedule ws"},
+		{name: "should lower desired to max of operator.", data: v1.WorkloadScheduleData{WorkloadScheduler: "ws", MaxReplicas: &five}, maxReplicas: "4",
+			desired: 10, want: 4, wantClamped: "desired 10 clamped to max 4 of operator"},
+		{name: "should prefer min over a lower max.", annotations: map[string]string{util.MinReplicasAnnotation: "3"}, data: v1.WorkloadScheduleData{WorkloadScheduler: "ws"}, maxReplicas: "1",
+			desired: 10, want: 3, wantClamped: "desired 10 clamped to max 1 of operator, raised to min 3 of annotation"},
+		{name: "should fail when annotation is not a replica count.", annotations: map[string]string{util.MinReplicasAnnotation: "quorum"}, data: v1.WorkloadScheduleData{WorkloadScheduler: "ws"},
+			desired: 0, wantErr: true},
+		{name: "should fail when env is not a replica count.", data: v1.WorkloadScheduleData{WorkloadScheduler: "ws"}, minReplicas: "-1", desired: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.minReplicas) != 0 {
+				t.Setenv(config.MinReplicas, tt.minReplicas)
+			}
+			if len(tt.maxReplicas) != 0 {
+				t.Setenv(config.MaxReplicas, tt.maxReplicas)
+			}
+			w := New()
+			workload := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			got, clamped, err := w.clampReplicas(workload, tt.data, tt.desired)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("clampReplicas() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("clampReplicas() got = %v, want %v", got, tt.want)
+			}
+			if clamped != tt.wantClamped {
+				t.Errorf("clampReplicas() clamped = %v, want %v", clamped, tt.wantClamped)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_AdjustReplicas_reportsClampedWorkloads(t *testing.T) {
+	deployment := newTestDeployment("dev", "etcd", 3, nil)
+	deployment.Annotations = map[string]string{util.MinReplicasAnnotation: "3"}
+	c := newTestClient(deployment)
+	w := New()
+
+	statuses, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "*", Desired: 0}}, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if err = c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *deployment.Spec.Replicas != 3 {
+		t.Errorf("executeAction() replicas = %v, want 3", *deployment.Spec.Replicas)
+	}
+	if got := statuses["ws"].ClampedWorkloads; len(got) != 1 || got[0] != "dev/deployment/etcd: desired 0 clamped to min 3 of annotation" {
+		t.Errorf("executeAction() clampedWorkloads = %v, want clamping of dev/deployment/etcd", got)
+	}
+}
+
+func TestWorkloadScheduleHandler_AdjustReplicas_boundsOnlyReplicaKinds(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "dev", Name: "workload"}
+	tests := []struct {
+		name     string
+		kind     string
+		workload client.Object
+	}{
+		{name: "should suspend cronjob below min replicas.", kind: util.CRONJOB, workload: &batch.CronJob{ObjectMeta: meta}},
+		{name: "should suspend job below min replicas.", kind: util.JOB, workload: &batch.Job{ObjectMeta: meta}},
+		{name: "should hibernate daemonset below min replicas.", kind: util.DAEMONSET, workload: &apps.DaemonSet{ObjectMeta: meta}},
+		{name: "should hibernate service below min replicas.", kind: util.SERVICE, workload: &core.Service{ObjectMeta: meta, Spec: core.ServiceSpec{Type: core.ServiceTypeLoadBalancer}}},
+		{name: "should switch resourcequota below min replicas.", kind: util.QUOTA, workload: &core.ResourceQuota{ObjectMeta: meta, Spec: core.ResourceQuotaSpec{Hard: core.ResourceList{core.ResourcePods: resource.MustParse("50")}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(config.MinReplicas, "2")
+			c := newTestClient(tt.workload)
+			w := New()
+			workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: tt.kind, Name: "workload", Desired: 0,
+				Quota: core.ResourceList{core.ResourcePods: resource.MustParse("0")}}}
+			for run := 0; run < 2; run++ {
+				statuses, err := w.executeAction(workloadSchedules, c, context.Background())
+				if err != nil {
+					t.Fatalf("executeAction() error = %v", err)
+				}
+				if got := w.getStatus(workloadSchedules[0], statuses).ClampedWorkloads; len(got) != 0 {
+					t.Errorf("executeAction() clampedWorkloads = %v, want none", got)
+				}
+			}
+			handler := w.WorkloadHandlers[tt.kind]
+			workloads, err := handler.ListWorkloads(context.Background(), c, client.InNamespace("dev"))
+			if err != nil || len(workloads) != 1 {
+				t.Fatalf("ListWorkloads() = %v, %v", workloads, err)
+			}
+			if applier, ok := handler.(WorkloadScheduleApplier); ok {
+				if !applier.InOrder(workloads[0], 0, workloadSchedules[0]) {
+					t.Errorf("executeAction() %s not switched", tt.kind)
+				}
+				return
+			}
+			if current, _ := handler.GetCurrent(context.Background(), c, workloads[0]); current != 0 {
+				t.Errorf("executeAction() current = %v, want 0", current)
+			}
+		})
+	}
+}
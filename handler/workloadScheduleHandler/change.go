@@ -0,0 +1,100 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"context"
+	"fmt"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// workloadChange is the change of a workload from its current to its desired replicas, evaluated by adjustWorkload.
+type workloadChange struct {
+	workloadSchedule workloadschedulerv1.WorkloadScheduleData
+	kind             string
+	handler          WorkloadHandler
+	workload         client.Object
+	current          int32
+	desired          int32
+	// rollingBack is set when desired brings the workload back to its replicas before a scale up that failed its verification.
+	rollingBack bool
+}
+
+// changeHook runs for the change of a workload, it returns true when the change is handled or waits, so the workload
+// is not applied in this run. A hook may replace the desired replicas of the change.
+type changeHook func(w *WorkloadScheduleHandler, r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool
+
+// appliedHook runs once the change of a workload is applied.
+type appliedHook func(w *WorkloadScheduleHandler, r client.Client, ctx context.Context, pass *actionPass, change *workloadChange)
+
+// preApplyHooks run in order before the workload is checked to be in order with its desired replicas.
+var preApplyHooks = []changeHook{
+	(*WorkloadScheduleHandler).awaitDependencies,
+	(*WorkloadScheduleHandler).resizeWorkload,
+	(*WorkloadScheduleHandler).scaleByScaledObject,
+	(*WorkloadScheduleHandler).scaleByHorizontalPodAutoscaler,
+	(*WorkloadScheduleHandler).awaitVerification,
+}
+
+// preWriteHooks run in order once the change is within the limits of the run, right before the workload is written.
+var preWriteHooks = []changeHook{
+	(*WorkloadScheduleHandler).awaitScaleDown,
+}
+
+// appliedHooks run in order once the workload is written.
+var appliedHooks = []appliedHook{
+	(*WorkloadScheduleHandler).clearAppliedBaseline,
+	(*WorkloadScheduleHandler).recordVerification,
+}
+
+func (w *WorkloadScheduleHandler) awaitDependencies(r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool {
+	return change.current != change.desired && w.waitForDependencies(change.workloadSchedule, r, ctx, pass, change.kind, change.handler, change.workload, change.current, change.desired)
+}
+
+// resizeWorkload adjusts the resources of the workload along its replicas, a failed resize does not hold back the replicas.
+func (w *WorkloadScheduleHandler) resizeWorkload(r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool {
+	if change.workloadSchedule.Resources == nil {
+		return false
+	}
+	if err := w.adjustResources(change.workloadSchedule, r, ctx, pass, change.kind, change.workload); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to resize %s for workloadschedule %s. Namespace: %s, Name: %s", change.kind, change.workloadSchedule.WorkloadScheduler, change.workload.GetNamespace(), change.workload.GetName()))
+		w.getStatus(change.workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(change.workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s/%s/%s: %v", change.workload.GetNamespace(), change.kind, change.workload.GetName(), err))
+	}
+	return false
+}
+
+// scaleByScaledObject adjusts the ScaledObject scaling the workload instead of the workload, KEDA overrides its replicas.
+func (w *WorkloadScheduleHandler) scaleByScaledObject(r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool {
+	return w.adjustScaledObject(change.workloadSchedule, r, ctx, pass, change.kind, change.workload, change.desired)
+}
+
+// scaleByHorizontalPodAutoscaler adjusts the bounds of the HorizontalPodAutoscaler targeting the workload instead of the
+// workload, the horizontalpodautoscaler stops scaling a workload at 0 replicas so the workload is still applied when it
+// is or goes to 0.
+func (w *WorkloadScheduleHandler) scaleByHorizontalPodAutoscaler(r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool {
+	if change.workloadSchedule.HPAPolicy != workloadschedulerv1.HPAPolicyAdjustBounds || change.desired == 0 {
+		return false
+	}
+	return w.adjustHorizontalPodAutoscaler(change.workloadSchedule, r, ctx, pass, change.kind, change.workload, change.desired) && change.current > 0
+}
+
+// awaitVerification holds a workload rolled back after failing its verification at its previous replicas until it is
+// scheduled to others.
+func (w *WorkloadScheduleHandler) awaitVerification(r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool {
+	change.desired, change.rollingBack = w.verifyReadiness(change.workloadSchedule, r, ctx, pass, change.kind, change.workload, change.current, change.desired)
+	return false
+}
+
+func (w *WorkloadScheduleHandler) clearAppliedBaseline(r client.Client, ctx context.Context, _ *actionPass, change *workloadChange) {
+	if err := clearBaseline(ctx, r, change.handler, change.workload, change.desired); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to clear baseline of %s. Namespace: %s, Name: %s", change.kind, change.workload.GetNamespace(), change.workload.GetName()))
+	}
+}
+
+func (w *WorkloadScheduleHandler) recordVerification(r client.Client, ctx context.Context, _ *actionPass, change *workloadChange) {
+	if change.rollingBack {
+		w.completeRollback(change.workloadSchedule, r, ctx, change.kind, change.workload, change.desired)
+	} else if err := startVerification(ctx, r, change.workloadSchedule, change.workload, change.current, change.desired); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to record verification of %s. Namespace: %s, Name: %s", change.kind, change.workload.GetNamespace(), change.workload.GetName()))
+	}
+}
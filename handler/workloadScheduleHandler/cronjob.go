@@ -0,0 +1,58 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"context"
+	batch "k8s.io/api/batch/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CronJobHandler suspends cronjobs when desired is 0 and resumes them when desired is above 0.
+//
+// The state is expressed as replicas, 0 being suspended and 1 active. Only cronjobs suspended by the operator are resumed,
+// they are marked with the util.SuspendedAnnotation.
+type CronJobHandler struct {
+	DesiredComputer
+}
+
+func NewCronJobHandler() *CronJobHandler {
+	return &CronJobHandler{}
+}
+
+func (c *CronJobHandler) NewWorkload() client.Object {
+	return &batch.CronJob{}
+}
+
+func (c *CronJobHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var cronJobWorkloads batch.CronJobList
+	if err := r.List(ctx, &cronJobWorkloads, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range cronJobWorkloads.Items {
+		workloads = append(workloads, &cronJobWorkloads.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (c *CronJobHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	return suspendToReplicas(workload.(*batch.CronJob).Spec.Suspend), nil
+}
+
+func (c *CronJobHandler) ComputeDesired(ctx context.Context, r client.Client, workload client.Object, current int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error) {
+	desired, err := c.DesiredComputer.ComputeDesired(ctx, r, workload, current, _workloadSchedule)
+	if err != nil {
+		return 0, err
+	}
+	return suspendDesired(workload, current, desired), nil
+}
+
+func (c *CronJobHandler) TogglesState() bool {
+	return true
+}
+
+func (c *CronJobHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	cronJob := workload.(*batch.CronJob)
+	cronJob.Spec.Suspend = applySuspend(cronJob, desired)
+	return r.Update(ctx, cronJob)
+}
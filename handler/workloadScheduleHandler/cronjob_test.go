@@ -0,0 +1,51 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	batch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestCronJobHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		cronJob        *batch.CronJob
+		desired        int32
+		wantSuspend    bool
+		wantAnnotation bool
+	}{
+		{name: "should suspend active cronjob and remember it.", cronJob: &batch.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "report"}},
+			desired: 0, wantSuspend: true, wantAnnotation: true},
+		{name: "should resume cronjob suspended by the operator.", cronJob: &batch.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "report", Annotations: map[string]string{util.SuspendedAnnotation: "true"}}, Spec: batch.CronJobSpec{Suspend: pointer.Bool(true)}},
+			desired: 1, wantSuspend: false, wantAnnotation: false},
+		{name: "should not resume cronjob suspended by its owner.", cronJob: &batch.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "report"}, Spec: batch.CronJobSpec{Suspend: pointer.Bool(true)}},
+			desired: 1, wantSuspend: true, wantAnnotation: false},
+		{name: "should leave active cronjob active when desired is above 1.", cronJob: &batch.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "report"}, Spec: batch.CronJobSpec{Suspend: pointer.Bool(false)}},
+			desired: 3, wantSuspend: false, wantAnnotation: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(tt.cronJob)
+			w := New()
+			_, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.CRONJOB, Name: "report", Desired: tt.desired}}, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			cronJob := &batch.CronJob{}
+			if err = c.Get(context.Background(), client.ObjectKeyFromObject(tt.cronJob), cronJob); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend; got != tt.wantSuspend {
+				t.Errorf("executeAction() suspend = %v, want %v", got, tt.wantSuspend)
+			}
+			if _, got := cronJob.Annotations[util.SuspendedAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	apps "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DaemonSetHandler hibernates daemonsets when desired is 0 by replacing their node selector with one no node satisfies,
+// the original node selector is recorded on the daemonset and restored when desired is above 0.
+type DaemonSetHandler struct {
+	DesiredComputer
+}
+
+func NewDaemonSetHandler() *DaemonSetHandler {
+	return &DaemonSetHandler{}
+}
+
+func (d *DaemonSetHandler) NewWorkload() client.Object {
+	return &apps.DaemonSet{}
+}
+
+func (d *DaemonSetHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var daemonSetWorkloads apps.DaemonSetList
+	if err := r.List(ctx, &daemonSetWorkloads, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range daemonSetWorkloads.Items {
+		workloads = append(workloads, &daemonSetWorkloads.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (d *DaemonSetHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	if _, ok := workload.GetAnnotations()[util.OriginalNodeSelectorAnnotation]; ok {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (d *DaemonSetHandler) ComputeDesired(ctx context.Context, r client.Client, workload client.Object, current int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error) {
+	desired, err := d.DesiredComputer.ComputeDesired(ctx, r, workload, current, _workloadSchedule)
+	if err != nil || desired == 0 {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (d *DaemonSetHandler) TogglesState() bool {
+	return true
+}
+
+func (d *DaemonSetHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	daemonSet := workload.(*apps.DaemonSet)
+	annotations := daemonSet.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	// a daemonset is only hibernated once and only restored from its record, the record of a hibernated daemonset is kept.
+	if _, hibernated := annotations[util.OriginalNodeSelectorAnnotation]; hibernated == (desired == 0) {
+		return nil
+	}
+	if desired == 0 {
+		originalNodeSelector, err := json.Marshal(daemonSet.Spec.Template.Spec.NodeSelector)
+		if err != nil {
+			return err
+		}
+		annotations[util.OriginalNodeSelectorAnnotation] = string(originalNodeSelector)
+		daemonSet.Spec.Template.Spec.NodeSelector = map[string]string{util.HibernatedNodeSelectorKey: "true"}
+	} else {
+		var originalNodeSelector map[string]string
+		if err := json.Unmarshal([]byte(annotations[util.OriginalNodeSelectorAnnotation]), &originalNodeSelector); err != nil {
+			return err
+		}
+		delete(annotations, util.OriginalNodeSelectorAnnotation)
+		daemonSet.Spec.Template.Spec.NodeSelector = originalNodeSelector
+	}
+	daemonSet.SetAnnotations(annotations)
+	return r.Update(ctx, daemonSet)
+}
@@ -0,0 +1,87 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestDaemonSetHandler(t *testing.T) {
+	newDaemonSet := func(annotations map[string]string, nodeSelector map[string]string) *apps.DaemonSet {
+		return &apps.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "log-shipper", Annotations: annotations},
+			Spec: apps.DaemonSetSpec{Template: core.PodTemplateSpec{Spec: core.PodSpec{NodeSelector: nodeSelector}}}}
+	}
+	hibernated := map[string]string{util.HibernatedNodeSelectorKey: "true"}
+	tests := []struct {
+		name             string
+		daemonSet        *apps.DaemonSet
+		desired          int32
+		wantNodeSelector map[string]string
+		wantAnnotation   bool
+	}{
+		{name: "should hibernate daemonset and record its node selector.", daemonSet: newDaemonSet(nil, map[string]string{"kubernetes.io/os": "linux"}),
+			desired: 0, wantNodeSelector: hibernated, wantAnnotation: true},
+		{name: "should restore node selector of hibernated daemonset.", daemonSet: newDaemonSet(map[string]string{util.OriginalNodeSelectorAnnotation: `{"kubernetes.io/os":"linux"}`}, hibernated),
+			desired: 2, wantNodeSelector: map[string]string{"kubernetes.io/os": "linux"}, wantAnnotation: false},
+		{name: "should restore empty node selector of hibernated daemonset.", daemonSet: newDaemonSet(map[string]string{util.OriginalNodeSelectorAnnotation: "null"}, hibernated),
+			desired: 1, wantNodeSelector: nil, wantAnnotation: false},
+		{name: "should leave running daemonset untouched.", daemonSet: newDaemonSet(nil, map[string]string{"kubernetes.io/os": "linux"}),
+			desired: 1, wantNodeSelector: map[string]string{"kubernetes.io/os": "linux"}, wantAnnotation: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(tt.daemonSet)
+			w := New()
+			_, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DAEMONSET, Name: "log-shipper", Desired: tt.desired}}, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			daemonSet := &apps.DaemonSet{}
+			if err = c.Get(context.Background(), client.ObjectKeyFromObject(tt.daemonSet), daemonSet); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := daemonSet.Spec.Template.Spec.NodeSelector; !reflect.DeepEqual(got, tt.wantNodeSelector) {
+				t.Errorf("executeAction() nodeSelector = %v, want %v", got, tt.wantNodeSelector)
+			}
+			if _, got := daemonSet.Annotations[util.OriginalNodeSelectorAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func TestDaemonSetHandler_Apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		desired     int32
+	}{
+		{name: "should not write running daemonset without record.", desired: 1},
+		{name: "should not write hibernated daemonset again.", annotations: map[string]string{util.OriginalNodeSelectorAnnotation: `{"kubernetes.io/os":"linux"}`}, desired: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daemonSet := &apps.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "log-shipper", Annotations: tt.annotations}}
+			c := newTestClient(daemonSet)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(daemonSet), daemonSet); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			resourceVersion := daemonSet.ResourceVersion
+			if err := NewDaemonSetHandler().Apply(context.Background(), c, daemonSet, tt.desired); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(daemonSet), daemonSet); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if daemonSet.ResourceVersion != resourceVersion {
+				t.Errorf("Apply() wrote the daemonset, resourceVersion %s, want %s", daemonSet.ResourceVersion, resourceVersion)
+			}
+		})
+	}
+}
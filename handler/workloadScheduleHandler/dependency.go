@@ -0,0 +1,229 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultDependencyTimeout is the time in seconds a change waits for dependencies before the wait is reported as an
+// error when DEPENDENCY_TIMEOUT sets none.
+const defaultDependencyTimeout = 600
+
+// dependencyWait is the change of a workload to desired replicas waiting for dependencies since the first run it waited.
+type dependencyWait struct {
+	desired int32
+	since   time.Time
+}
+
+// getReferenceKey returns kind/name of the workload reference.
+func getReferenceKey(reference workloadschedulerv1.WorkloadReference) string {
+	kind := reference.Kind
+	if len(kind) == 0 {
+		kind = util.DEPLOYMENT
+	}
+	return fmt.Sprintf("%s/%s", kind, reference.Name)
+}
+
+// validateDependencies rejects dependencies of a workload on itself and cycles between workloads.
+func validateDependencies(dependencies []workloadschedulerv1.WorkloadDependency) error {
+	graph := map[string][]string{}
+	for _, dependency := range dependencies {
+		workload := getReferenceKey(dependency.Workload)
+		for _, dependsOn := range dependency.DependsOn {
+			if getReferenceKey(dependsOn) == workload {
+				return fmt.Errorf("%s depends on itself", workload)
+			}
+			graph[workload] = append(graph[workload], getReferenceKey(dependsOn))
+		}
+	}
+	var workloads []string
+	for workload := range graph {
+		workloads = append(workloads, workload)
+	}
+	sort.Strings(workloads)
+
+	// workloads are visiting while their dependencies are walked, a dependency that is visiting closes a cycle.
+	const visiting, visited = 1, 2
+	state := map[string]int{}
+	var path []string
+	var walk func(workload string) error
+	walk = func(workload string) error {
+		switch state[workload] {
+		case visited:
+			return nil
+		case visiting:
+			for idx := range path {
+				if path[idx] == workload {
+					return fmt.Errorf("cycle %s", strings.Join(append(path[idx:], workload), " -> "))
+				}
+			}
+		}
+		state[workload] = visiting
+		path = append(path, workload)
+		for _, dependsOn := range graph[workload] {
+			if err := walk(dependsOn); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[workload] = visited
+		return nil
+	}
+	for _, workload := range workloads {
+		if err := walk(workload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForDependencies reports whether the change of the workload waits, a workload is scaled up once the workloads it
+// depends on are ready and scaled down once the workloads depending on it are scaled down. A workload whose dependencies
+// are not evaluated yet in this run is evaluated again at the end of it, so changes of a run follow the order of dependencies.
+func (w *WorkloadScheduleHandler) waitForDependencies(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, handler WorkloadHandler, workload client.Object, current int32, desired int32) bool {
+	scaleUp := desired > current
+	key := fmt.Sprintf("%s/%s", kind, workload.GetName())
+	var references []workloadschedulerv1.WorkloadReference
+	for _, dependency := range _workloadSchedule.Dependencies {
+		if scaleUp && getReferenceKey(dependency.Workload) == key {
+			references = append(references, dependency.DependsOn...)
+		}
+		if !scaleUp {
+			for _, dependsOn := range dependency.DependsOn {
+				if getReferenceKey(dependsOn) == key {
+					references = append(references, dependency.Workload)
+				}
+			}
+		}
+	}
+	if len(references) == 0 {
+		return false
+	}
+
+	if !pass.resolvingDependents {
+		for _, reference := range references {
+			if _, ok := pass.processedWorkloads[fmt.Sprintf("%s/%s", workload.GetNamespace(), getReferenceKey(reference))]; !ok {
+				pass.dependents = append(pass.dependents, follower{workloadSchedule: _workloadSchedule, kind: kind, handler: handler, workload: workload})
+				return true
+			}
+		}
+	}
+
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	for _, reference := range references {
+		waiting, err := w.getDependencyState(r, ctx, pass, reference, workload.GetNamespace(), scaleUp)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to check dependencies of %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+			w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s: %v", processedWorkloadKey, err))
+			return true
+		}
+		if len(waiting) != 0 {
+			since := w.startDependencyWait(pass, processedWorkloadKey, desired)
+			if timeout := w.getDependencyTimeout(); time.Since(since) > timeout {
+				err = fmt.Errorf("%s for more than %s since %s", waiting, timeout, since.Format(time.RFC3339))
+				log.Log.Error(err, fmt.Sprintf("%v update of NS: %v, Name: %v, from %v to %v timed out waiting for dependencies", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), current, desired))
+				w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s: %v", processedWorkloadKey, err))
+				return true
+			}
+			log.Log.Info(fmt.Sprintf("%v deferred update of NS: %v, Name: %v, from %v to %v, %s", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), current, desired, waiting))
+			w.getStatus(_workloadSchedule, pass.statuses).DeferredWorkloads = append(w.getStatus(_workloadSchedule, pass.statuses).DeferredWorkloads, fmt.Sprintf("%s: %s", processedWorkloadKey, waiting))
+			return true
+		}
+	}
+	return false
+}
+
+// startDependencyWait returns since when the change of the workload to desired waits for dependencies, a wait starts
+// again once the workload stops waiting or waits for other replicas.
+func (w *WorkloadScheduleHandler) startDependencyWait(pass *actionPass, processedWorkloadKey string, desired int32) time.Time {
+	pass.waitingWorkloads[processedWorkloadKey] = struct{}{}
+	if w.dependencyWaits == nil {
+		w.dependencyWaits = make(map[string]dependencyWait)
+	}
+	wait, ok := w.dependencyWaits[processedWorkloadKey]
+	if !ok || wait.desired != desired {
+		wait = dependencyWait{desired: desired, since: time.Now()}
+		w.dependencyWaits[processedWorkloadKey] = wait
+	}
+	return wait.since
+}
+
+// getDependencyTimeout returns how long a change waits for dependencies before the wait is reported as an error.
+func (w *WorkloadScheduleHandler) getDependencyTimeout() time.Duration {
+	timeout := defaultDependencyTimeout
+	if _, ok := w.Config.Provider.LookUpEnv(config.DependencyTimeout); ok {
+		if _timeout, err := w.Config.LookUpIntEnv(config.DependencyTimeout); err != nil || _timeout < 1 {
+			log.Log.Error(fmt.Errorf("invalid %s env, expected a positive number", config.DependencyTimeout), "using the default dependency timeout")
+		} else {
+			timeout = _timeout
+		}
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// getDependencyState returns why the referenced workload holds back a change, empty when it does not. On scale up the
+// referenced workload needs to be at its scheduled replicas with all of them ready, a workload scheduled to or left at 0
+// replicas holds back none. On scale down its pods need to be down to its scheduled replicas.
+func (w *WorkloadScheduleHandler) getDependencyState(r client.Client, ctx context.Context, pass *actionPass, reference workloadschedulerv1.WorkloadReference, namespace string, scaleUp bool) (string, error) {
+	referenceKey := getReferenceKey(reference)
+	handler, handlerKind, err := w.getWorkloadHandler(r, workloadschedulerv1.WorkloadScheduleData{Namespace: namespace, Kind: strings.Split(referenceKey, "/")[0], Name: reference.Name})
+	if err != nil {
+		return "", err
+	}
+	workloads, err := handler.ListWorkloads(ctx, r, client.InNamespace(namespace), client.MatchingFields{config.IndexedField: reference.Name})
+	if err != nil {
+		return "", err
+	}
+	if len(workloads) == 0 {
+		if scaleUp {
+			return fmt.Sprintf("waiting for %s/%s, not found", namespace, referenceKey), nil
+		}
+		return "", nil
+	}
+	replicas, err := handler.GetCurrent(ctx, r, workloads[0])
+	if err != nil {
+		return "", err
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(workloads[0])
+	if err != nil {
+		return "", err
+	}
+	podReplicas, _, _ := unstructured.NestedInt64(object, "status", "replicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(object, "status", "readyReplicas")
+
+	scheduled, ok := pass.scheduledReplicas[fmt.Sprintf("%s/%s/%s", namespace, handlerKind, reference.Name)]
+	if ok && scheduled != replicas {
+		return fmt.Sprintf("waiting for %s/%s to be scaled to %d", namespace, referenceKey, scheduled), nil
+	}
+	if scaleUp && readyReplicas < int64(replicas) {
+		return fmt.Sprintf("waiting for %s/%s to be ready, %d/%d ready", namespace, referenceKey, readyReplicas, replicas), nil
+	}
+	if !scaleUp && podReplicas > int64(replicas) {
+		return fmt.Sprintf("waiting for %s/%s to be scaled down, %d/%d replicas", namespace, referenceKey, podReplicas, replicas), nil
+	}
+	return "", nil
+}
+
+// adjustDependents evaluates again the workloads whose dependencies were not evaluated yet when they were, the waits of
+// workloads that did not wait for dependencies in this run are dropped.
+func (w *WorkloadScheduleHandler) adjustDependents(r client.Client, ctx context.Context, pass *actionPass) {
+	pass.resolvingDependents = true
+	for _, dependent := range pass.dependents {
+		w.adjustWorkload(dependent.workloadSchedule, r, ctx, pass, dependent.kind, dependent.handler, dependent.workload)
+	}
+	for processedWorkloadKey := range w.dependencyWaits {
+		if _, ok := pass.waitingWorkloads[processedWorkloadKey]; !ok {
+			delete(w.dependencyWaits, processedWorkloadKey)
+		}
+	}
+}
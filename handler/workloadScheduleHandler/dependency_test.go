@@ -0,0 +1,181 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_validateDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies []v1.WorkloadDependency
+		wantErr      string
+	}{
+		{name: "should accept dependencies without cycle.", dependencies: []v1.WorkloadDependency{
+			{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Name: "cache"}, {Kind: util.STATEFULSET, Name: "db"}}},
+			{Workload: v1.WorkloadReference{Name: "cache"}, DependsOn: []v1.WorkloadReference{{Kind: util.STATEFULSET, Name: "db"}}},
+		}},
+		{name: "should reject workload depending on itself.", dependencies: []v1.WorkloadDependency{
+			{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Kind: util.DEPLOYMENT, Name: "api"}}},
+		}, wantErr: "deployment/api depends on itself"},
+		{name: "should reject cycle.", dependencies: []v1.WorkloadDependency{
+			{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Name: "cache"}}},
+			{Workload: v1.WorkloadReference{Name: "cache"}, DependsOn: []v1.WorkloadReference{{Kind: util.STATEFULSET, Name: "db"}}},
+			{Workload: v1.WorkloadReference{Kind: util.STATEFULSET, Name: "db"}, DependsOn: []v1.WorkloadReference{{Name: "api"}}},
+		}, wantErr: "cycle deployment/api -> deployment/cache -> statefulset/db -> deployment/api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDependencies(tt.dependencies)
+			if (err != nil) != (len(tt.wantErr) != 0) || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("validateDependencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_waitForDependencies(t *testing.T) {
+	dependencies := []v1.WorkloadDependency{{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Name: "db"}}}}
+	tests := []struct {
+		name string
+		// replicas of api and db before the first run.
+		replicas  int32
+		desired   int32
+		settle    func(deployments map[string]*apps.Deployment)
+		wantFirst map[string]int32
+	}{
+		{name: "should scale up dependent once its dependency is ready.", replicas: 0, desired: 1, settle: func(deployments map[string]*apps.Deployment) {
+			deployments["db"].Status.ReadyReplicas = 1
+		}, wantFirst: map[string]int32{"api": 0, "db": 1}},
+		{name: "should scale down dependency once its dependent is scaled down.", replicas: 1, desired: 0, settle: func(deployments map[string]*apps.Deployment) {
+			deployments["api"].Status.Replicas = 0
+		}, wantFirst: map[string]int32{"api": 0, "db": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []client.Object
+			for _, name := range []string{"api", "db"} {
+				deployment := newTestDeployment("dev", name, tt.replicas, nil)
+				deployment.Status = apps.DeploymentStatus{Replicas: tt.replicas, ReadyReplicas: tt.replicas}
+				objs = append(objs, deployment)
+			}
+			c := newTestClient(objs...)
+			w := New()
+			workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "*", Desired: tt.desired, Dependencies: dependencies}}
+			getDeployments := func() map[string]*apps.Deployment {
+				deployments := map[string]*apps.Deployment{}
+				for _, name := range []string{"api", "db"} {
+					deployment := &apps.Deployment{}
+					if err := c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: name}, deployment); err != nil {
+						t.Fatalf("Get() error = %v", err)
+					}
+					deployments[name] = deployment
+				}
+				return deployments
+			}
+
+			statuses, err := w.executeAction(workloadSchedules, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			deployments := getDeployments()
+			for name, want := range tt.wantFirst {
+				if *deployments[name].Spec.Replicas != want {
+					t.Errorf("executeAction() first run %s replicas = %v, want %v", name, *deployments[name].Spec.Replicas, want)
+				}
+			}
+			if got := len(w.getStatus(workloadSchedules[0], statuses).DeferredWorkloads); got != 1 {
+				t.Errorf("executeAction() first run deferred = %v, want 1", got)
+			}
+
+			tt.settle(deployments)
+			for _, deployment := range deployments {
+				if err = c.Status().Update(context.Background(), deployment); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+			if _, err = w.executeAction(workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			for name, deployment := range getDeployments() {
+				if *deployment.Spec.Replicas != tt.desired {
+					t.Errorf("executeAction() second run %s replicas = %v, want %v", name, *deployment.Spec.Replicas, tt.desired)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_waitForDependencies_zeroReplicas(t *testing.T) {
+	dependencies := []v1.WorkloadDependency{{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Name: "db"}}}}
+	tests := []struct {
+		name              string
+		workloadSchedules []v1.WorkloadScheduleData
+	}{
+		{name: "should not wait for a dependency scheduled to 0.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 1, Dependencies: dependencies},
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "db", Desired: 0, Dependencies: dependencies},
+		}},
+		{name: "should not wait for a dependency at 0 no schedule applies to.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 1, Dependencies: dependencies},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestDeployment("dev", "api", 0, nil)
+			c := newTestClient(api, newTestDeployment("dev", "db", 0, nil))
+			w := New()
+			statuses, err := w.executeAction(tt.workloadSchedules, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err = c.Get(context.Background(), client.ObjectKeyFromObject(api), api); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *api.Spec.Replicas != 1 {
+				t.Errorf("executeAction() replicas = %v, want 1", *api.Spec.Replicas)
+			}
+			if got := w.getStatus(tt.workloadSchedules[0], statuses).DeferredWorkloads; len(got) != 0 {
+				t.Errorf("executeAction() deferred = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_waitForDependencies_timeout(t *testing.T) {
+	c := newTestClient(newTestDeployment("dev", "api", 0, nil))
+	w := New()
+	workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 1,
+		Dependencies: []v1.WorkloadDependency{{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Name: "db"}}}}}}
+
+	statuses, err := w.executeAction(workloadSchedules, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if got := w.getStatus(workloadSchedules[0], statuses); len(got.DeferredWorkloads) != 1 || len(got.WorkloadErrors) != 0 {
+		t.Errorf("executeAction() deferred = %v, errors = %v, want the wait deferred", got.DeferredWorkloads, got.WorkloadErrors)
+	}
+
+	// the wait started before the timeout.
+	w.dependencyWaits["dev/deployment/api"] = dependencyWait{desired: 1, since: time.Now().Add(-time.Hour)}
+	if statuses, err = w.executeAction(workloadSchedules, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if got := w.getStatus(workloadSchedules[0], statuses); len(got.DeferredWorkloads) != 0 || len(got.WorkloadErrors) != 1 || !strings.Contains(got.WorkloadErrors[0], "waiting for dev/deployment/db, not found for more than 10m0s") {
+		t.Errorf("executeAction() deferred = %v, errors = %v, want the wait reported as an error", got.DeferredWorkloads, got.WorkloadErrors)
+	}
+
+	// the wait is dropped once the workload does not wait anymore.
+	if _, err = w.executeAction(nil, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if len(w.dependencyWaits) != 0 {
+		t.Errorf("executeAction() dependencyWaits = %v, want none", w.dependencyWaits)
+	}
+}
@@ -0,0 +1,46 @@
+package workloadScheduleHandler
+
+import (
+	"context"
+	apps "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeploymentHandler adjusts spec.replicas of deployments.
+type DeploymentHandler struct {
+	DesiredComputer
+}
+
+func NewDeploymentHandler() *DeploymentHandler {
+	return &DeploymentHandler{}
+}
+
+func (d *DeploymentHandler) NewWorkload() client.Object {
+	return &apps.Deployment{}
+}
+
+func (d *DeploymentHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var deploymentWorkloads apps.DeploymentList
+	if err := r.List(ctx, &deploymentWorkloads, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range deploymentWorkloads.Items {
+		workloads = append(workloads, &deploymentWorkloads.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (d *DeploymentHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	deployment := workload.(*apps.Deployment)
+	if deployment.Spec.Replicas == nil {
+		return 1, nil
+	}
+	return *deployment.Spec.Replicas, nil
+}
+
+func (d *DeploymentHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	deployment := workload.(*apps.Deployment)
+	deployment.Spec.Replicas = &desired
+	return r.Update(ctx, deployment)
+}
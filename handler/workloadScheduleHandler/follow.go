@@ -0,0 +1,110 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	"math"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+)
+
+func validateFollow(follow workloadschedulerv1.Follow) error {
+	if len(follow.Name) == 0 {
+		return fmt.Errorf("name needs to be defined")
+	}
+	if _, err := getFollowRatio(follow); err != nil {
+		return err
+	}
+	if follow.Min != nil && follow.Max != nil && *follow.Min > *follow.Max {
+		return fmt.Errorf("min %d is greater than max %d", *follow.Min, *follow.Max)
+	}
+	return nil
+}
+
+func getFollowRatio(follow workloadschedulerv1.Follow) (float64, error) {
+	if len(follow.Ratio) == 0 {
+		return 1, nil
+	}
+	ratio, err := strconv.ParseFloat(follow.Ratio, 64)
+	if err != nil || ratio < 0 {
+		return 0, fmt.Errorf("ratio %s is not a non-negative number", follow.Ratio)
+	}
+	return ratio, nil
+}
+
+// computeFollowDesired applies the ratio of follow to the followed replicas, rounded up and kept within min and max.
+func computeFollowDesired(follow workloadschedulerv1.Follow, followed int32) (int32, error) {
+	ratio, err := getFollowRatio(follow)
+	if err != nil {
+		return 0, err
+	}
+	replicas := math.Ceil(float64(followed) * ratio)
+	if follow.Min != nil && replicas < float64(*follow.Min) {
+		replicas = float64(*follow.Min)
+	}
+	if follow.Max != nil && replicas > float64(*follow.Max) {
+		replicas = float64(*follow.Max)
+	}
+	if replicas > math.MaxInt32 {
+		return 0, fmt.Errorf("ratio %s of %d replicas exceeds %d", follow.Ratio, followed, math.MaxInt32)
+	}
+	return int32(replicas), nil
+}
+
+// adjustFollowers adjusts the workloads following another workload once every other workload is adjusted in this run,
+// followers are resolved in order so a follower may follow a workload following another.
+func (w *WorkloadScheduleHandler) adjustFollowers(r client.Client, ctx context.Context, pass *actionPass) {
+	for _, _follower := range pass.followers {
+		_workloadSchedule := _follower.workloadSchedule
+		workload := _follower.workload
+		followed, err := w.getFollowedReplicas(r, ctx, pass, *_workloadSchedule.Follow, workload.GetNamespace())
+		if err == nil {
+			_workloadSchedule.Desired, err = computeFollowDesired(*_workloadSchedule.Follow, followed)
+		}
+		if err != nil {
+			processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), _follower.kind, workload.GetName())
+			log.Log.Error(err, fmt.Sprintf("failed to compute desired state of %s for workloadschedule %s. Namespace: %s, Name: %s", _follower.kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+			w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s: %v", processedWorkloadKey, err))
+			continue
+		}
+		if w.Config.LookUpBooleanEnv(config.Debug) {
+			log.Log.Info(fmt.Sprintf("%s follows %s/%s with %d replicas, desired %d. NS: %s, WS: %s", workload.GetName(), _workloadSchedule.Follow.Kind, _workloadSchedule.Follow.Name, followed, _workloadSchedule.Desired, workload.GetNamespace(), _workloadSchedule.WorkloadScheduler))
+		}
+		_workloadSchedule.Follow = nil
+		_workloadSchedule.Percentage = nil
+		_workloadSchedule.DesiredExpression = ""
+		w.adjustWorkload(_workloadSchedule, r, ctx, pass, _follower.kind, _follower.handler, workload)
+	}
+}
+
+// getFollowedReplicas returns the replicas the followed workload is scheduled to in this run, or its current replicas.
+func (w *WorkloadScheduleHandler) getFollowedReplicas(r client.Client, ctx context.Context, pass *actionPass, follow workloadschedulerv1.Follow, namespace string) (int32, error) {
+	if len(follow.Namespace) != 0 {
+		namespace = follow.Namespace
+	}
+	kind := follow.Kind
+	if len(kind) == 0 {
+		kind = util.DEPLOYMENT
+	}
+	handler, handlerKind, err := w.getWorkloadHandler(r, workloadschedulerv1.WorkloadScheduleData{Namespace: namespace, Kind: kind, Name: follow.Name})
+	if err != nil {
+		return 0, err
+	}
+	if follow.Replicas != workloadschedulerv1.FollowReplicasCurrent {
+		if scheduled, ok := pass.scheduledReplicas[fmt.Sprintf("%s/%s/%s", namespace, handlerKind, follow.Name)]; ok {
+			return scheduled, nil
+		}
+	}
+	workloads, err := handler.ListWorkloads(ctx, r, client.InNamespace(namespace), client.MatchingFields{config.IndexedField: follow.Name})
+	if err != nil {
+		return 0, err
+	}
+	if len(workloads) == 0 {
+		return 0, fmt.Errorf("followed %s %s not found in namespace %s", kind, follow.Name, namespace)
+	}
+	return handler.GetCurrent(ctx, r, workloads[0])
+}
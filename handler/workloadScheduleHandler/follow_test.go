@@ -0,0 +1,108 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+)
+
+func TestComputeFollowDesired(t *testing.T) {
+	one, four := int32(1), int32(4)
+	tests := []struct {
+		name     string
+		follow   v1.Follow
+		followed int32
+		want     int32
+		wantErr  bool
+	}{
+		{name: "should follow replicas when ratio is empty.", follow: v1.Follow{Name: "api"}, followed: 3, want: 3},
+		{name: "should round ratio of replicas up.", follow: v1.Follow{Name: "api", Ratio: "0.5"}, followed: 3, want: 2},
+		{name: "should raise replicas to min.", follow: v1.Follow{Name: "api", Ratio: "0.5", Min: &one}, followed: 0, want: 1},
+		{name: "should lower replicas to max.", follow: v1.Follow{Name: "api", Ratio: "2", Max: &four}, followed: 3, want: 4},
+		{name: "should fail when ratio is not a number.", follow: v1.Follow{Name: "api", Ratio: "half"}, followed: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := computeFollowDesired(tt.follow, tt.followed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("computeFollowDesired() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("computeFollowDesired() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFollow(t *testing.T) {
+	one, four := int32(1), int32(4)
+	tests := []struct {
+		name    string
+		follow  v1.Follow
+		wantErr bool
+	}{
+		{name: "should accept follow within bounds.", follow: v1.Follow{Name: "api", Ratio: "0.5", Min: &one, Max: &four}},
+		{name: "should fail when name is empty.", follow: v1.Follow{Ratio: "0.5"}, wantErr: true},
+		{name: "should fail when ratio is negative.", follow: v1.Follow{Name: "api", Ratio: "-1"}, wantErr: true},
+		{name: "should fail when min is greater than max.", follow: v1.Follow{Name: "api", Min: &four, Max: &one}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateFollow(tt.follow); (err != nil) != tt.wantErr {
+				t.Errorf("validateFollow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_adjustFollowers(t *testing.T) {
+	tests := []struct {
+		name              string
+		workloadSchedules []v1.WorkloadScheduleData
+		wantReplicas      int32
+		wantErr           bool
+	}{
+		{name: "should follow scheduled replicas of the followed workload in the same run.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "cache", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "cart-cache", Follow: &v1.Follow{Name: "cart-api", Ratio: "0.5"}},
+			{WorkloadScheduler: "api", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "cart-api", Desired: 6},
+		}, wantReplicas: 3},
+		{name: "should follow current replicas of the followed workload.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "cache", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "cart-cache", Follow: &v1.Follow{Name: "cart-api", Ratio: "0.5", Replicas: v1.FollowReplicasCurrent}},
+		}, wantReplicas: 1},
+		{name: "should follow current replicas when no workload schedule applies to the followed workload.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "cache", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "cart-cache", Follow: &v1.Follow{Name: "cart-api", Ratio: "2"}},
+		}, wantReplicas: 4},
+		{name: "should keep replicas of higher ranked workload schedule over follow.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "cache", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "cart-cache", Desired: 5},
+			{WorkloadScheduler: "cache-follow", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "*", Follow: &v1.Follow{Name: "cart-api"}},
+		}, wantReplicas: 5},
+		{name: "should report error when followed workload is not found.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "cache", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "cart-cache", Follow: &v1.Follow{Name: "checkout-api"}},
+		}, wantReplicas: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(newTestDeployment("dev", "cart-api", 2, nil), newTestDeployment("dev", "cart-cache", 2, nil))
+			w := New()
+			statuses, err := w.executeAction(tt.workloadSchedules, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			deployment := &apps.Deployment{}
+			if err = c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: "cart-cache"}, deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *deployment.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, tt.wantReplicas)
+			}
+			if status, ok := statuses["cache"]; tt.wantErr && (!ok || len(status.WorkloadErrors) != 1 || !strings.HasPrefix(status.WorkloadErrors[0], "dev/deployment/cart-cache: ")) {
+				t.Errorf("executeAction() workloadErrors = %v, want error of dev/deployment/cart-cache", statuses["cache"])
+			}
+		})
+	}
+}
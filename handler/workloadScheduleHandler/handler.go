@@ -5,52 +5,55 @@ import (
 	"bennsimon.github.io/workload-scheduler-operator/handler/scheduleHandler"
 	"bennsimon.github.io/workload-scheduler-operator/util"
 	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"bennsimon.github.io/workload-scheduler-operator/util/expression"
 	"context"
 	"fmt"
-	apps "k8s.io/api/apps/v1"
+	"github.com/google/cel-go/cel"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"math"
+	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 type WorkloadScheduleHandler struct {
-	ScheduleHandler scheduleHandler.IScheduleHandler
+	ScheduleHandler  scheduleHandler.IScheduleHandler
+	WorkloadHandlers WorkloadHandlerRegistry
 	config.Config
-}
-
-type WorkloadHandler interface {
-	AdjustReplicas(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Writer, ctx context.Context, processedWorkloads map[string]string)
+	// trackedKinds are the kinds changed by workload schedules by the key of the change, objects of these kinds are
+	// restored once no schedule changes them that way.
+	trackedKinds map[string]map[string]struct{}
+	// APIReader reads the objects the operator does not watch from the API server, the client of the run is used when nil.
+	APIReader client.Reader
+	// Recorder emits the events of workloads failing verification, events are not emitted when nil.
+	Recorder record.EventRecorder
+	// dependencyWaits are the changes waiting for dependencies by processed workload key.
+	dependencyWaits map[string]dependencyWait
+	// writeLimiter caps the writes to the API server to WRITE_QPS, created on the first run it is set.
+	writeLimiter flowcontrol.RateLimiter
 }
 
 type IWorkloadScheduleHandler interface {
 	EvaluateWorkloadSchedulers(schedulers *workloadschedulerv1.WorkloadScheduleList, r client.Reader, ctx context.Context) (map[string][]workloadschedulerv1.Schedule, map[string]workloadschedulerv1.WorkloadSchedule)
 	ProcessWorkloadSchedules(schedules map[string][]workloadschedulerv1.Schedule, schedulerMap map[string]workloadschedulerv1.WorkloadSchedule, c client.Client, ctx context.Context) error
 	ValidateWorkloadSchedule(schedule *workloadschedulerv1.WorkloadSchedule, r client.Reader) error
+	// IndexedWorkloads returns the types of workloads listed from the manager's cache by name.
+	IndexedWorkloads() []client.Object
 }
 
-type DeploymentHandler struct {
-	config.Config
-	apps.Deployment
-}
-
-func NewDeploymentHandler(deployment *apps.Deployment) *DeploymentHandler {
-	return &DeploymentHandler{Config: *config.New(), Deployment: *deployment}
-}
-
-type StatefulSetHandler struct {
-	config.Config
-	apps.StatefulSet
-}
-
-func NewStatefulSetHandler(statefulSet *apps.StatefulSet) *StatefulSetHandler {
-	return &StatefulSetHandler{Config: *config.New(), StatefulSet: *statefulSet}
+func New() *WorkloadScheduleHandler {
+	return &WorkloadScheduleHandler{ScheduleHandler: scheduleHandler.New(), WorkloadHandlers: NewWorkloadHandlerRegistry(), Config: *config.New()}
 }
 
-func New() *WorkloadScheduleHandler {
-	return &WorkloadScheduleHandler{ScheduleHandler: scheduleHandler.New(), Config: *config.New()}
+func (w *WorkloadScheduleHandler) IndexedWorkloads() []client.Object {
+	return w.WorkloadHandlers.IndexedWorkloads()
 }
 
 func (w *WorkloadScheduleHandler) ValidateWorkloadSchedule(workloadSchedule *workloadschedulerv1.WorkloadSchedule, r client.Reader) error {
@@ -67,6 +70,62 @@ func (w *WorkloadScheduleHandler) ValidateWorkloadSchedule(workloadSchedule *wor
 			if err != nil {
 				return err
 			}
+
+			if schedule.Ramp != nil {
+				if _, _, err = getRampTimes(*schedule.Ramp, time.Now().In(time.Local)); err != nil {
+					return fmt.Errorf("ramp of schedule %s is not valid. %v", schedule.Schedule, err)
+				}
+			}
+
+			if schedule.Follow != nil {
+				if err = validateFollow(*schedule.Follow); err != nil {
+					return fmt.Errorf("follow of schedule %s is not valid. %v", schedule.Schedule, err)
+				}
+			}
+
+			if schedule.Resources != nil {
+				if err = validateResourceProfile(*schedule.Resources); err != nil {
+					return fmt.Errorf("resources of schedule %s is not valid. %v", schedule.Schedule, err)
+				}
+			}
+
+			if schedule.Action == workloadschedulerv1.ActionPatch {
+				if err = validatePatch(schedule.Patch); err != nil {
+					return fmt.Errorf("patch of schedule %s is not valid. %v", schedule.Schedule, err)
+				}
+			}
+
+			if quotaProfile := schedule.QuotaProfile; len(quotaProfile) != 0 {
+				if _, ok := workloadSchedule.Spec.QuotaProfiles[quotaProfile]; !ok {
+					return fmt.Errorf("quotaProfile: %s of schedule %s is not defined in quotaProfiles", quotaProfile, schedule.Schedule)
+				}
+			}
+
+			if desiredExpression := schedule.DesiredExpression; len(desiredExpression) != 0 {
+				if _, err = expression.CompileDesired(desiredExpression); err != nil {
+					return fmt.Errorf("desiredExpression: %s of schedule %s is not valid. %v", desiredExpression, schedule.Schedule, err)
+				}
+			}
+		}
+	}
+
+	if minReplicas, maxReplicas := workloadSchedule.Spec.MinReplicas, workloadSchedule.Spec.MaxReplicas; minReplicas != nil && maxReplicas != nil && *minReplicas > *maxReplicas {
+		return fmt.Errorf("minReplicas: %d is greater than maxReplicas: %d", *minReplicas, *maxReplicas)
+	}
+
+	if err := validateDependencies(workloadSchedule.Spec.Dependencies); err != nil {
+		return fmt.Errorf("dependencies are not valid. %v", err)
+	}
+
+	for _, kind := range workloadSchedule.Spec.Selector.Kinds {
+		if _, ok := w.WorkloadHandlers[kind]; !ok && !util.IsKindReference(kind) {
+			return fmt.Errorf("kind: %s is not valid, expected one of %v or Kind.version.group", kind, w.WorkloadHandlers.Kinds())
+		}
+	}
+
+	if filter := workloadSchedule.Spec.Selector.Filter; len(filter) != 0 {
+		if _, err := expression.CompileFilter(filter); err != nil {
+			return fmt.Errorf("filter: %s is not valid. %v", filter, err)
 		}
 	}
 	return nil
@@ -91,11 +150,98 @@ func (w *WorkloadScheduleHandler) EvaluateWorkloadSchedulers(workloadSchedulers
 func (w *WorkloadScheduleHandler) ProcessWorkloadSchedules(_workloadScheduleAndSchedules map[string][]workloadschedulerv1.Schedule, workloadSchedulerMap map[string]workloadschedulerv1.WorkloadSchedule, r client.Client, ctx context.Context) error {
 	var workloadScheduleAndSchedules = w.extractSchedulesOfInstant(_workloadScheduleAndSchedules, workloadSchedulerMap)
 	var workloadSchedules = w.RankWorkloadScheduleBySelectors(workloadScheduleAndSchedules)
+	for _, workloadSchedule := range workloadSchedulerMap {
+		w.trackPatchKinds(workloadSchedule)
+	}
+	if err := w.syncKinds(r, ctx); err != nil {
+		log.Log.Error(err, "failed to sync the record of changed kinds")
+	}
+
+	r = w.limitWrites(r)
+	statuses, err := w.executeAction(workloadSchedules, r, ctx)
+	if err != nil {
+		return err
+	}
+	w.updateStatuses(statuses, workloadSchedulerMap, r, ctx)
+	programs.prune()
+	return nil
+}
+
+// updateStatuses writes the status observed during this run on each workload schedule, when it changed.
+func (w *WorkloadScheduleHandler) updateStatuses(statuses map[string]*workloadschedulerv1.WorkloadScheduleStatus, workloadSchedulerMap map[string]workloadschedulerv1.WorkloadSchedule, r client.Client, ctx context.Context) {
+	for name, workloadSchedule := range workloadSchedulerMap {
+		status := workloadschedulerv1.WorkloadScheduleStatus{}
+		if _status, ok := statuses[name]; ok {
+			status = *_status
+		}
+		sort.Strings(status.PausedJobs)
+		sort.Strings(status.WorkloadErrors)
+		sort.Strings(status.ClampedWorkloads)
+		sort.Strings(status.DeferredWorkloads)
+		sort.Strings(status.DegradedWorkloads)
+		status.Conditions = getConditions(workloadSchedule, status.DegradedWorkloads)
+		if reflect.DeepEqual(workloadSchedule.Status, status) {
+			continue
+		}
+		workloadSchedule.Status = status
+		if err := r.Status().Update(ctx, &workloadSchedule); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to update status of workloadschedule %s.", name))
+		}
+	}
+}
+
+// BuildSpecMap adds the workload schedule data of the unit of _workloadSchedule for schedule to specMap, startTime is the start of the active occurrence of schedule
+// and now the instant the schedules are evaluated for.
+func (w *WorkloadScheduleHandler) BuildSpecMap(_workloadSchedule workloadschedulerv1.WorkloadSchedule, specMap map[string]map[string][]workloadschedulerv1.WorkloadScheduleData, schedule workloadschedulerv1.Schedule, startTime time.Time, now time.Time) {
+	workloadScheduleUnit, err := w.getWorkloadScheduleUnit(schedule, _workloadSchedule.Spec.Schedules)
+	if err != nil {
+		log.Log.Error(err, "error occurred when matching schedules")
+		return
+	}
+	if workloadScheduleUnit.Ramp != nil {
+		desired, err := w.getRampDesired(*workloadScheduleUnit.Ramp, now)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("error occurred when computing ramp of schedule %s for %s ws", schedule.Name, _workloadSchedule.Name))
+			return
+		}
+		workloadScheduleUnit.Desired = desired
+	}
+	w.buildSpecMapOfUnit(_workloadSchedule, specMap, workloadScheduleUnit, fmt.Sprintf("%s@%s", schedule.Name, startTime.Format(time.RFC3339)), &metav1.Time{Time: startTime})
+}
+
+// getRampDesired interpolates desired linearly between ramp.From at ramp.Start and ramp.To at ramp.End for now.
+func (w *WorkloadScheduleHandler) getRampDesired(ramp workloadschedulerv1.Ramp, now time.Time) (int32, error) {
+	startTime, endTime, err := getRampTimes(ramp, now)
+	if err != nil {
+		return 0, err
+	}
+	if !now.After(startTime) {
+		return ramp.From, nil
+	}
+	if !now.Before(endTime) {
+		return ramp.To, nil
+	}
+	progress := float64(now.Sub(startTime)) / float64(endTime.Sub(startTime))
+	return ramp.From + int32(math.Round(float64(ramp.To-ramp.From)*progress)), nil
+}
 
-	return w.executeAction(workloadSchedules, r, ctx)
+func getRampTimes(ramp workloadschedulerv1.Ramp, now time.Time) (time.Time, time.Time, error) {
+	startTime, err := util.ProcessScheduleTimeUnit(ramp.Start, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	endTime, err := util.ProcessScheduleTimeUnit(ramp.End, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !endTime.After(startTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("ramp end %s is not after start %s", endTime, startTime)
+	}
+	return startTime, endTime, nil
 }
 
-func (w *WorkloadScheduleHandler) BuildSpecMap(_workloadSchedule workloadschedulerv1.WorkloadSchedule, specMap map[string]map[string][]workloadschedulerv1.WorkloadScheduleData, schedule workloadschedulerv1.Schedule) {
+// buildSpecMapOfUnit adds the workload schedule data of the selectors of _workloadSchedule with the values of workloadScheduleUnit to specMap.
+func (w *WorkloadScheduleHandler) buildSpecMapOfUnit(_workloadSchedule workloadschedulerv1.WorkloadSchedule, specMap map[string]map[string][]workloadschedulerv1.WorkloadScheduleData, workloadScheduleUnit workloadschedulerv1.WorkloadScheduleUnit, occurrence string, startTime *metav1.Time) {
 	_workloadScheduleSelector := _workloadSchedule.Spec.Selector
 	namespaces := _workloadScheduleSelector.Namespaces
 	names := _workloadScheduleSelector.Names
@@ -128,6 +274,12 @@ func (w *WorkloadScheduleHandler) BuildSpecMap(_workloadSchedule workloadschedul
 	}
 
 	keyStr := string(keysArr)
+
+	// the expressions are compiled once and shared by the data of all the selectors of the workload schedule.
+	if _, _, err := compileExpressions(workloadschedulerv1.WorkloadScheduleData{Filter: _workloadSchedule.Spec.Selector.Filter, DesiredExpression: workloadScheduleUnit.DesiredExpression}); err != nil {
+		log.Log.Error(err, fmt.Sprintf("skipped, invalid expressions for workloadschedule %s.", _workloadSchedule.Name))
+		return
+	}
 	//
 	var extractedKeys [][]string
 
@@ -145,13 +297,20 @@ func (w *WorkloadScheduleHandler) BuildSpecMap(_workloadSchedule workloadschedul
 		if specMap[keyStr] == nil {
 			specMap[keyStr] = make(map[string][]workloadschedulerv1.WorkloadScheduleData)
 		}
-		if desired, err := w.getDesired(schedule, _workloadSchedule.Spec.Schedules); err == nil {
-			workloadScheduleData := workloadschedulerv1.WorkloadScheduleData{Labels: _workloadSchedule.Spec.Selector.Labels, WorkloadScheduler: _workloadSchedule.Name, Namespace: _keyComb[0], Kind: _keyComb[1], Name: _keyComb[2], Desired: desired}
-			specMap[keyStr][keyComb] = append(specMap[keyStr][keyComb], workloadScheduleData)
-		} else {
-			log.Log.Error(err, "error occurred when matching schedules")
-			break
+		workloadScheduleData := workloadschedulerv1.WorkloadScheduleData{Labels: _workloadSchedule.Spec.Selector.Labels, Filter: _workloadSchedule.Spec.Selector.Filter, WorkloadScheduler: _workloadSchedule.Name, Namespace: _keyComb[0], Kind: _keyComb[1], Name: _keyComb[2], Desired: workloadScheduleUnit.Desired, Percentage: workloadScheduleUnit.Percentage, DesiredExpression: workloadScheduleUnit.DesiredExpression,
+			HPAPolicy: _workloadSchedule.Spec.HPAPolicy, HPA: workloadScheduleUnit.HPA, Knative: workloadScheduleUnit.Knative, Follow: workloadScheduleUnit.Follow, Resources: workloadScheduleUnit.Resources,
+			MinReplicas: _workloadSchedule.Spec.MinReplicas, MaxReplicas: _workloadSchedule.Spec.MaxReplicas, Quota: _workloadSchedule.Spec.QuotaProfiles[workloadScheduleUnit.QuotaProfile],
+			Snapshot: workloadScheduleUnit.Snapshot, Dependencies: _workloadSchedule.Spec.Dependencies, Verify: workloadScheduleUnit.Verify,
+			StartTime: startTime}
+		switch workloadScheduleUnit.Action {
+		case workloadschedulerv1.ActionRestart:
+			workloadScheduleData.Action = workloadScheduleUnit.Action
+			workloadScheduleData.Occurrence = occurrence
+		case workloadschedulerv1.ActionPatch:
+			workloadScheduleData.Action = workloadScheduleUnit.Action
+			workloadScheduleData.Patch = workloadScheduleUnit.Patch
 		}
+		specMap[keyStr][keyComb] = append(specMap[keyStr][keyComb], workloadScheduleData)
 	}
 }
 
@@ -182,103 +341,13 @@ func (w *WorkloadScheduleHandler) RankWorkloadScheduleBySelectors(_specMap map[s
 	return _workloadSchedules
 }
 
-func (d *DeploymentHandler) AdjustReplicas(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Writer, ctx context.Context, processedWorkloads map[string]string) {
-	deployment := d.Deployment
-	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", deployment.Namespace, util.DEPLOYMENT, deployment.Name)
-	if _, ok := d.Config.GetIgnoredNamespacesMap()[deployment.Namespace]; !ok {
-		if d.Config.LookUpBooleanEnv(config.Debug) {
-			log.Log.Info(fmt.Sprintf("fetched: %s .... %v", processedWorkloadKey, processedWorkloads))
-		}
-		if _, ok := processedWorkloads[processedWorkloadKey]; !ok {
-			deploymentSpec := deployment.Spec
-			currentReplicaCount := *deploymentSpec.Replicas
-
-			if currentReplicaCount != _workloadSchedule.Desired {
-				//if d.Config.LookUpBooleanEnv(config.Debug) {
-				log.Log.Info(fmt.Sprintf("%v updating NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, deployment.Namespace, deployment.Name, currentReplicaCount, _workloadSchedule.Desired))
-				//}
-				*deploymentSpec.Replicas = _workloadSchedule.Desired
-				err := r.Update(ctx, &deployment)
-				if err != nil {
-					log.Log.Error(err, fmt.Sprintf("failed to update %s from %d to %d for workloadschedule %s.", util.DEPLOYMENT, currentReplicaCount, _workloadSchedule.Desired, _workloadSchedule.WorkloadScheduler))
-				} else {
-					//if d.Config.LookUpBooleanEnv(config.Debug) {
-					log.Log.Info(fmt.Sprintf("%v updated NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, deployment.Namespace, deployment.Name, currentReplicaCount, _workloadSchedule.Desired))
-
-					//}
-				}
-			} else {
-				//if d.Config.LookUpBooleanEnv(config.Debug) {
-				log.Log.Info(fmt.Sprintf("got %s %s in order with %s. Namespace: %s, Name: %s, Desired: %d", deployment.Name, util.DEPLOYMENT, _workloadSchedule.WorkloadScheduler, deployment.Namespace, deployment.Name, _workloadSchedule.Desired))
-				//}
-			}
-			processedWorkloads[processedWorkloadKey] = processedWorkloadKey
-
-		} else {
-			if d.Config.LookUpBooleanEnv(config.Debug) {
-				log.Log.Info(fmt.Sprintf("skipped in loop: NS: %s, Kind: %s, Name: %s.", _workloadSchedule.Namespace, _workloadSchedule.Kind, _workloadSchedule.Name))
-			}
-		}
-	} else {
-		if d.Config.LookUpBooleanEnv(config.Debug) {
-			log.Log.Info(fmt.Sprintf("ignored workload in %s namespace.", deployment.Namespace))
-		}
-	}
-}
-
-func (w *StatefulSetHandler) AdjustReplicas(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Writer, ctx context.Context, processedWorkloads map[string]string) {
-	statefulSet := w.StatefulSet
-	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", statefulSet.Namespace, util.STATEFULSET, statefulSet.Name)
-	if _, ok := w.Config.GetIgnoredNamespacesMap()[statefulSet.Namespace]; !ok {
-		if w.Config.LookUpBooleanEnv(config.Debug) {
-			log.Log.Info(fmt.Sprintf("fetched: %s .... %v", processedWorkloadKey, processedWorkloads))
-		}
-		if _, ok := processedWorkloads[processedWorkloadKey]; !ok {
-			statefulSetSpec := statefulSet.Spec
-			currentReplicaCount := *statefulSet.Spec.Replicas
-
-			if *statefulSet.Spec.Replicas != _workloadSchedule.Desired {
-				//if w.Config.LookUpBooleanEnv(config.Debug) {
-				log.Log.Info(fmt.Sprintf("%v updating NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, statefulSet.Namespace, statefulSet.Name, currentReplicaCount, _workloadSchedule.Desired))
-				//}
-				*statefulSetSpec.Replicas = _workloadSchedule.Desired
-				err := r.Update(ctx, &statefulSet)
-				if err != nil {
-					log.Log.Error(err, fmt.Sprintf("failed to update %s from %d to %d for workloadschedule %s.", util.STATEFULSET, currentReplicaCount, _workloadSchedule.Desired, _workloadSchedule.WorkloadScheduler))
-				} else {
-					//if w.Config.LookUpBooleanEnv(config.Debug) {
-					log.Log.Info(fmt.Sprintf("%v updated NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, statefulSet.Namespace, statefulSet.Name, currentReplicaCount, _workloadSchedule.Desired))
-					//}
-				}
-			} else {
-				//if w.Config.LookUpBooleanEnv(config.Debug) {
-				log.Log.Info(fmt.Sprintf("got %s %s in order with %s. Namespace: %s, Name: %s, Desired: %d", statefulSet.Name, util.STATEFULSET, _workloadSchedule.WorkloadScheduler, statefulSet.Namespace, statefulSet.Name, _workloadSchedule.Desired))
-				//}
-			}
-			processedWorkloads[processedWorkloadKey] = processedWorkloadKey
-
-		} else {
-			if w.Config.LookUpBooleanEnv(config.Debug) {
-				log.Log.Info(fmt.Sprintf("skipped in loop: NS: %s, Kind: %s, Name: %s", _workloadSchedule.Namespace, _workloadSchedule.Kind, _workloadSchedule.Name))
-			}
-		}
-	} else {
-		if w.Config.LookUpBooleanEnv(config.Debug) {
-			log.Log.Info(fmt.Sprintf("Ignored workload in %s namespace", statefulSet.Namespace))
-		}
-	}
-}
-
-func (w *WorkloadScheduleHandler) AdjustReplicas(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Writer, ctx context.Context, processedWorkloads map[string]string, handler WorkloadHandler) {
-	handler.AdjustReplicas(_workloadSchedule, r, ctx, processedWorkloads)
-}
-
 func (w *WorkloadScheduleHandler) extractSchedulesOfInstant(_workloadScheduleAndSchedules map[string][]workloadschedulerv1.Schedule, workloadSchedulerMap map[string]workloadschedulerv1.WorkloadSchedule) map[string]map[string][]workloadschedulerv1.WorkloadScheduleData {
 	var specMap = make(map[string]map[string][]workloadschedulerv1.WorkloadScheduleData)
 	now := time.Now().In(time.Local)
 
 	for workloadScheduleName, _schedules := range _workloadScheduleAndSchedules {
 		if _workloadSchedule, ok := workloadSchedulerMap[workloadScheduleName]; ok {
+			isAnyScheduleActive := false
 			for _, schedule := range _schedules {
 				scheduleSpec := schedule.Spec
 				scheduleUnits := scheduleSpec.ScheduleUnits
@@ -309,7 +378,8 @@ func (w *WorkloadScheduleHandler) extractSchedulesOfInstant(_workloadScheduleAnd
 						}
 
 						if now.After(startTime) && now.Before(endTime) {
-							w.BuildSpecMap(_workloadSchedule, specMap, schedule)
+							w.BuildSpecMap(_workloadSchedule, specMap, schedule, startTime, now)
+							isAnyScheduleActive = true
 							break
 						} else {
 							if w.Config.LookUpBooleanEnv(config.Debug) {
@@ -319,14 +389,20 @@ func (w *WorkloadScheduleHandler) extractSchedulesOfInstant(_workloadScheduleAnd
 					}
 				}
 			}
+			if !isAnyScheduleActive && _workloadSchedule.Spec.DefaultDesired != nil {
+				if w.Config.LookUpBooleanEnv(config.Debug) {
+					log.Log.Info(fmt.Sprintf("no schedule of %s ws active for now: %s, using default desired %d", _workloadSchedule.Name, now, *_workloadSchedule.Spec.DefaultDesired))
+				}
+				w.buildSpecMapOfUnit(_workloadSchedule, specMap, workloadschedulerv1.WorkloadScheduleUnit{Desired: *_workloadSchedule.Spec.DefaultDesired}, "", nil)
+			}
 		}
 	}
 
 	return specMap
 }
 
-func (w *WorkloadScheduleHandler) executeAction(_workloadSchedules []workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context) error {
-	processedWorkloads := make(map[string]string)
+func (w *WorkloadScheduleHandler) executeAction(_workloadSchedules []workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context) (map[string]*workloadschedulerv1.WorkloadScheduleStatus, error) {
+	pass := newActionPass()
 
 	for _, _workloadSchedule := range _workloadSchedules {
 		namespace := _workloadSchedule.Namespace
@@ -335,8 +411,9 @@ func (w *WorkloadScheduleHandler) executeAction(_workloadSchedules []workloadsch
 		labels := _workloadSchedule.Labels
 
 		keyComb := fmt.Sprintf("%s/%s/%s", namespace, kind, name)
-		_, exists := processedWorkloads[keyComb]
-		if !exists {
+		_, exists := pass.processedWorkloads[keyComb]
+		// restarts and patches do not claim the replicas of workloads, so they are evaluated even when the workloads are processed.
+		if !exists || _workloadSchedule.Action == workloadschedulerv1.ActionRestart || _workloadSchedule.Action == workloadschedulerv1.ActionPatch {
 			if w.Config.LookUpBooleanEnv(config.Debug) {
 				log.Log.Info(fmt.Sprintf("evaluating: %s,  NS: %s, Kind: %s, Name: %s", _workloadSchedule.WorkloadScheduler, namespace, kind, name))
 			}
@@ -353,11 +430,18 @@ func (w *WorkloadScheduleHandler) executeAction(_workloadSchedules []workloadsch
 				opts = append(opts, client.MatchingLabels(_workloadSchedule.Labels))
 			}
 
-			if kind == util.DEPLOYMENT {
-				w.executeActionOnDeployment(r, ctx, opts, _workloadSchedule, processedWorkloads)
-			} else if kind == util.STATEFULSET {
-				w.executeActionOnStatefulSet(r, ctx, opts, _workloadSchedule, processedWorkloads)
+			filter, _, err := compileExpressions(_workloadSchedule)
+			if err != nil {
+				log.Log.Error(err, fmt.Sprintf("skipped, invalid expressions for workloadschedule %s.", _workloadSchedule.WorkloadScheduler))
+				continue
 			}
+
+			handler, handlerKind, err := w.getWorkloadHandler(r, _workloadSchedule)
+			if err != nil {
+				log.Log.Error(err, fmt.Sprintf("skipped, no handler for kind %s of workloadschedule %s.", kind, _workloadSchedule.WorkloadScheduler))
+				continue
+			}
+			w.executeActionOnKind(r, ctx, opts, _workloadSchedule, pass, filter, handlerKind, handler)
 		} else {
 			if w.Config.LookUpBooleanEnv(config.Debug) {
 				log.Log.Info(fmt.Sprintf("skipped: NS: %s, Kind: %s, Name: %s", namespace, kind, name))
@@ -365,44 +449,101 @@ func (w *WorkloadScheduleHandler) executeAction(_workloadSchedules []workloadsch
 		}
 	}
 
-	return nil
+	w.adjustFollowers(r, ctx, pass)
+	w.adjustDependents(r, ctx, pass)
+
+	w.restoreBaselines(r, ctx, pass)
+	w.restoreWorkloads(r, ctx, pass)
+	w.restoreScaledObjects(r, ctx, pass)
+	w.restoreHorizontalPodAutoscalers(r, ctx, pass)
+	w.restoreResources(r, ctx, pass)
+	w.revertPatches(r, ctx, pass)
+	return pass.statuses, nil
 }
 
-func (w *WorkloadScheduleHandler) executeActionOnStatefulSet(r client.Client, ctx context.Context, opts []client.ListOption, _workloadSchedule workloadschedulerv1.WorkloadScheduleData, processedWorkloads map[string]string) {
-	var statefulSetWorkloads apps.StatefulSetList
-	err := r.List(ctx, &statefulSetWorkloads, opts...)
-	if err != nil {
-		log.Log.Error(err, fmt.Sprintf("error occurred when fetching %s", util.STATEFULSET))
-	} else {
-		if len(statefulSetWorkloads.Items) == 0 && w.Config.LookUpBooleanEnv(config.Debug) {
-			log.Log.Info(fmt.Sprintf("%s: %s not found. NS: %s, WS: %s", util.STATEFULSET, _workloadSchedule.Name, _workloadSchedule.Namespace, _workloadSchedule.WorkloadScheduler))
+// compileExpressions returns the programs of the filter and the desired expression of _workloadSchedule, nil for the
+// expressions it does not have. Programs are compiled once per expression and shared by every workload schedule with it.
+func compileExpressions(_workloadSchedule workloadschedulerv1.WorkloadScheduleData) (cel.Program, cel.Program, error) {
+	var filter, desired cel.Program
+	var err error
+	if len(_workloadSchedule.Filter) != 0 {
+		if filter, err = programs.get(_workloadSchedule.Filter, expression.CompileFilter); err != nil {
+			return nil, nil, fmt.Errorf("filter: %s is not valid. %v", _workloadSchedule.Filter, err)
 		}
-		for _, statefulSet := range statefulSetWorkloads.Items {
-			w.AdjustReplicas(_workloadSchedule, r, ctx, processedWorkloads, NewStatefulSetHandler(&statefulSet))
+	}
+	if len(_workloadSchedule.DesiredExpression) != 0 {
+		if desired, err = programs.get(_workloadSchedule.DesiredExpression, expression.CompileDesired); err != nil {
+			return nil, nil, fmt.Errorf("desiredExpression: %s is not valid. %v", _workloadSchedule.DesiredExpression, err)
 		}
 	}
+	return filter, desired, nil
 }
 
-func (w *WorkloadScheduleHandler) executeActionOnDeployment(r client.Client, ctx context.Context, opts []client.ListOption, _workloadSchedule workloadschedulerv1.WorkloadScheduleData, processedWorkloads map[string]string) {
-	var deploymentWorkloads apps.DeploymentList
-	err := r.List(ctx, &deploymentWorkloads, opts...)
-	if err != nil {
-		log.Log.Error(err, fmt.Sprintf("error occurred when fetching %s", util.DEPLOYMENT))
-	} else {
-		if len(deploymentWorkloads.Items) == 0 && w.Config.LookUpBooleanEnv(config.Debug) {
-			log.Log.Info(fmt.Sprintf("%s: %s not found. NS: %s, WS: %s", util.DEPLOYMENT, _workloadSchedule.Name, _workloadSchedule.Namespace, _workloadSchedule.WorkloadScheduler))
+// programs holds the programs compiled from the expressions of workload schedules by their source.
+var programs = &programCache{}
+
+type programCache struct {
+	mu       sync.Mutex
+	compiled map[string]cel.Program
+	// used are the sources looked up since the last prune.
+	used map[string]struct{}
+}
+
+func (c *programCache) get(source string, compile func(string) (cel.Program, error)) (cel.Program, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compiled == nil {
+		c.compiled, c.used = make(map[string]cel.Program), make(map[string]struct{})
+	}
+	program, ok := c.compiled[source]
+	if !ok {
+		var err error
+		if program, err = compile(source); err != nil {
+			return nil, err
 		}
-		for _, deployment := range deploymentWorkloads.Items {
-			w.AdjustReplicas(_workloadSchedule, r, ctx, processedWorkloads, NewDeploymentHandler(&deployment))
+		c.compiled[source] = program
+	}
+	c.used[source] = struct{}{}
+	return program, nil
+}
+
+// prune drops the programs not looked up since the last prune, their expressions were removed from the workload schedules.
+func (c *programCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for source := range c.compiled {
+		if _, ok := c.used[source]; !ok {
+			delete(c.compiled, source)
 		}
 	}
+	c.used = make(map[string]struct{})
+}
+
+func (w *WorkloadScheduleHandler) matchesFilter(filter cel.Program, workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) bool {
+	if filter == nil {
+		return true
+	}
+	matches, err := expression.EvaluateFilter(filter, workload)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to evaluate filter for NS: %s, Name: %s, WS: %s", workload.GetNamespace(), workload.GetName(), _workloadSchedule.WorkloadScheduler))
+		return false
+	}
+	if !matches && w.Config.LookUpBooleanEnv(config.Debug) {
+		log.Log.Info(fmt.Sprintf("filtered out NS: %s, Name: %s, WS: %s", workload.GetNamespace(), workload.GetName(), _workloadSchedule.WorkloadScheduler))
+	}
+	return matches
 }
 
 func (w *WorkloadScheduleHandler) getDesired(schedule workloadschedulerv1.Schedule, schedules []workloadschedulerv1.WorkloadScheduleUnit) (int32, error) {
+	workloadScheduleUnit, err := w.getWorkloadScheduleUnit(schedule, schedules)
+	return workloadScheduleUnit.Desired, err
+}
+
+func (w *WorkloadScheduleHandler) getWorkloadScheduleUnit(schedule workloadschedulerv1.Schedule, schedules []workloadschedulerv1.WorkloadScheduleUnit) (workloadschedulerv1.WorkloadScheduleUnit, error) {
 	for _, workloadScheduleUnit := range schedules {
 		if schedule.Name == workloadScheduleUnit.Schedule {
-			return workloadScheduleUnit.Desired, nil
+			return workloadScheduleUnit, nil
 		}
 	}
-	return 0, fmt.Errorf("no matching schedule found for %s", schedule.Name)
+	return workloadschedulerv1.WorkloadScheduleUnit{}, fmt.Errorf("no matching schedule found for %s", schedule.Name)
 }
@@ -3,14 +3,30 @@ package workloadScheduleHandler
 import (
 	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
 	"bennsimon.github.io/workload-scheduler-operator/handler/scheduleHandler"
+	"bennsimon.github.io/workload-scheduler-operator/util"
 	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"bennsimon.github.io/workload-scheduler-operator/util/expression"
 	"context"
 	"fmt"
+	"github.com/google/cel-go/cel"
 	"github.com/stretchr/testify/mock"
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
 	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"testing"
+	"time"
 )
 
 func TestWorkloadScheduleHandler_getDesired(t *testing.T) {
@@ -206,8 +222,8 @@ func TestWorkloadScheduleHandler_BuildSpecMap(t *testing.T) {
 			schedule: v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "test-schedule"}},
 		}, want: map[string]map[string][]v1.WorkloadScheduleData{
 			"0110": {
-				"*/deployment/test-deploy":  []v1.WorkloadScheduleData{{Name: "test-deploy", Namespace: "*", Kind: "deployment", Desired: 0, WorkloadScheduler: "test-workload-scheduler"}},
-				"*/statefulset/test-deploy": []v1.WorkloadScheduleData{{Name: "test-deploy", Namespace: "*", Kind: "statefulset", Desired: 0, WorkloadScheduler: "test-workload-scheduler"}},
+				"*/deployment/test-deploy":  []v1.WorkloadScheduleData{{Name: "test-deploy", Namespace: "*", Kind: "deployment", Desired: 0, WorkloadScheduler: "test-workload-scheduler", StartTime: &metav1.Time{}}},
+				"*/statefulset/test-deploy": []v1.WorkloadScheduleData{{Name: "test-deploy", Namespace: "*", Kind: "statefulset", Desired: 0, WorkloadScheduler: "test-workload-scheduler", StartTime: &metav1.Time{}}},
 			},
 		},
 		},
@@ -225,8 +241,8 @@ func TestWorkloadScheduleHandler_BuildSpecMap(t *testing.T) {
 			schedule: v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "test-schedule"}},
 		}, want: map[string]map[string][]v1.WorkloadScheduleData{
 			"0100": {
-				"*/deployment/*":  []v1.WorkloadScheduleData{{Name: "*", Namespace: "*", Kind: "deployment", Desired: 0, WorkloadScheduler: "test-workload-scheduler"}},
-				"*/statefulset/*": []v1.WorkloadScheduleData{{Name: "*", Namespace: "*", Kind: "statefulset", Desired: 0, WorkloadScheduler: "test-workload-scheduler"}},
+				"*/deployment/*":  []v1.WorkloadScheduleData{{Name: "*", Namespace: "*", Kind: "deployment", Desired: 0, WorkloadScheduler: "test-workload-scheduler", StartTime: &metav1.Time{}}},
+				"*/statefulset/*": []v1.WorkloadScheduleData{{Name: "*", Namespace: "*", Kind: "statefulset", Desired: 0, WorkloadScheduler: "test-workload-scheduler", StartTime: &metav1.Time{}}},
 			},
 		},
 		},
@@ -244,7 +260,7 @@ func TestWorkloadScheduleHandler_BuildSpecMap(t *testing.T) {
 			schedule: v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "test-schedule"}},
 		}, want: map[string]map[string][]v1.WorkloadScheduleData{
 			"1110": {
-				"default/deployment/test-deploy": []v1.WorkloadScheduleData{{Name: "test-deploy", Namespace: "default", Kind: "deployment", Desired: 0, WorkloadScheduler: "test-workload-scheduler"}},
+				"default/deployment/test-deploy": []v1.WorkloadScheduleData{{Name: "test-deploy", Namespace: "default", Kind: "deployment", Desired: 0, WorkloadScheduler: "test-workload-scheduler", StartTime: &metav1.Time{}}},
 			},
 		},
 		},
@@ -252,7 +268,7 @@ func TestWorkloadScheduleHandler_BuildSpecMap(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := &WorkloadScheduleHandler{}
-			w.BuildSpecMap(tt.args._workloadSchedule, tt.args.specMap, tt.args.schedule)
+			w.BuildSpecMap(tt.args._workloadSchedule, tt.args.specMap, tt.args.schedule, time.Time{}, time.Now())
 			if !reflect.DeepEqual(tt.args.specMap, tt.want) {
 				t.Errorf("BuildSpecMap() got = %v want %v", tt.args.specMap, tt.want)
 			}
@@ -290,12 +306,24 @@ func TestWorkloadScheduleHandler_ValidateWorkloadSchedule(t *testing.T) {
 		{name: "should return error if schedule name is invalid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: ".weekday"}}}}}, wantErr: true},
 		{name: "should return error if schedule name is invalid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: ""}}}}}, wantErr: true},
 		{name: "should not return error if schedule name is valid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}}}}, wantErr: false},
+		{name: "should return error if kind is not supported", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}, Selector: v1.WorkloadSelector{Kinds: []string{"Deployment"}}}}}, wantErr: true},
+		{name: "should not return error if kind is a group kind reference", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}, Selector: v1.WorkloadSelector{Kinds: []string{"deployment", "Rollout.v1alpha1.argoproj.io"}}}}}, wantErr: false},
+		{name: "should return error if filter is invalid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}, Selector: v1.WorkloadSelector{Filter: "workload.spec.replicas +"}}}}, wantErr: true},
+		{name: "should return error if filter does not evaluate to bool", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}, Selector: v1.WorkloadSelector{Filter: "'weekday'"}}}}, wantErr: true},
+		{name: "should return error if desiredExpression is invalid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday", DesiredExpression: "baseline > 2"}}}}}, wantErr: true},
+		{name: "should not return error if desiredExpression is valid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday", DesiredExpression: "baseline / 2"}}}}}, wantErr: false},
+		{name: "should return error if ramp ends before it starts", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday", Ramp: &v1.Ramp{From: 2, To: 30, Start: v1.TimeUnit{Time: "08:30:00"}, End: v1.TimeUnit{Time: "07:30:00"}}}}}}}, wantErr: true},
+		{name: "should return error if quotaProfile is not defined", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday", QuotaProfile: "night"}}}}}, wantErr: true},
+		{name: "should not return error if quotaProfile is defined", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{QuotaProfiles: map[string]core.ResourceList{"night": {}}, Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday", QuotaProfile: "night"}}}}}, wantErr: false},
+		{name: "should return error if ramp time is invalid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday", Ramp: &v1.Ramp{From: 2, To: 30, Start: v1.TimeUnit{Time: "7:30"}, End: v1.TimeUnit{Time: "08:30:00"}}}}}}}, wantErr: true},
+		{name: "should not return error if filter is valid", fields: fields{ScheduleHandler: &NopScheduleHandler{}}, args: args{workloadSchedule: &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}, Selector: v1.WorkloadSelector{Filter: "workload.spec.replicas > 2"}}}}, wantErr: false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			w := &WorkloadScheduleHandler{
-				ScheduleHandler: tt.fields.ScheduleHandler,
-				Config:          tt.fields.Config,
+				ScheduleHandler:  tt.fields.ScheduleHandler,
+				WorkloadHandlers: NewWorkloadHandlerRegistry(),
+				Config:           tt.fields.Config,
 			}
 			if err := w.ValidateWorkloadSchedule(tt.args.workloadSchedule, tt.args.r); (err != nil) != tt.wantErr {
 				t.Errorf("ValidateWorkloadSchedule() error = %v, wantErr %v", err, tt.wantErr)
@@ -303,3 +331,311 @@ func TestWorkloadScheduleHandler_ValidateWorkloadSchedule(t *testing.T) {
 		})
 	}
 }
+
+func newTestClient(objs ...client.Object) client.Client {
+	indexer := func(rawObj client.Object) []string {
+		return []string{rawObj.GetName()}
+	}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{apps.SchemeGroupVersion, scaledObjectGVK.GroupVersion(), knativeServiceGVK.GroupVersion(), volumeSnapshotGVK.GroupVersion()})
+	for _, kind := range []string{"Deployment", "StatefulSet", "ReplicaSet"} {
+		mapper.Add(apps.SchemeGroupVersion.WithKind(kind), meta.RESTScopeNamespace)
+	}
+	mapper.Add(scaledObjectGVK, meta.RESTScopeNamespace)
+	mapper.Add(knativeServiceGVK, meta.RESTScopeNamespace)
+	mapper.Add(volumeSnapshotGVK, meta.RESTScopeNamespace)
+	knativeService := &unstructured.Unstructured{}
+	knativeService.SetGroupVersionKind(knativeServiceGVK)
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithRESTMapper(mapper).WithStatusSubresource(&v1.WorkloadSchedule{}, &v1.WorkloadScheduleController{}).
+		WithIndex(&apps.Deployment{}, config.IndexedField, indexer).
+		WithIndex(&apps.StatefulSet{}, config.IndexedField, indexer).
+		WithIndex(&apps.ReplicaSet{}, config.IndexedField, indexer).
+		WithIndex(&apps.DaemonSet{}, config.IndexedField, indexer).
+		WithIndex(&batch.CronJob{}, config.IndexedField, indexer).
+		WithIndex(&batch.Job{}, config.IndexedField, indexer).
+		WithIndex(&core.ResourceQuota{}, config.IndexedField, indexer).
+		WithIndex(&core.Service{}, config.IndexedField, indexer).
+		WithIndex(knativeService, config.IndexedField, indexer).
+		WithInterceptorFuncs(interceptor.Funcs{SubResourceGet: getScale, SubResourceUpdate: updateScale}).
+		Build()
+}
+
+// getScale emulates the scale subresource which is not supported by the fake client.
+func getScale(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	if subResourceName != scaleSubResource {
+		return c.SubResource(subResourceName).Get(ctx, obj, subResource, opts...)
+	}
+	workload := obj.(*unstructured.Unstructured)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(workload), workload); err != nil {
+		return err
+	}
+	replicas, _, _ := unstructured.NestedInt64(workload.Object, "spec", "replicas")
+	return unstructured.SetNestedField(subResource.(*unstructured.Unstructured).Object, replicas, "spec", "replicas")
+}
+
+func updateScale(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if subResourceName != scaleSubResource {
+		return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+	}
+	updateOptions := client.SubResourceUpdateOptions{}
+	updateOptions.ApplyOptions(opts)
+	replicas, _, _ := unstructured.NestedInt64(updateOptions.SubResourceBody.(*unstructured.Unstructured).Object, "spec", "replicas")
+	workload := obj.(*unstructured.Unstructured)
+	if err := unstructured.SetNestedField(workload.Object, replicas, "spec", "replicas"); err != nil {
+		return err
+	}
+	return c.Update(ctx, workload)
+}
+
+func newTestDeployment(namespace string, name string, replicas int32, labels map[string]string) *apps.Deployment {
+	return &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}, Spec: apps.DeploymentSpec{Replicas: pointer.Int32(replicas)}}
+}
+
+func TestWorkloadScheduleHandler_executeActionOnScalable(t *testing.T) {
+	tests := []struct {
+		name              string
+		workloadSchedules []v1.WorkloadScheduleData
+		want              map[string]int32
+	}{
+		{name: "should adjust replicas through the scale subresource.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "default", Kind: "ReplicaSet.v1.apps", Name: "rs-a", Desired: 0},
+		}, want: map[string]int32{"rs-a": 0, "rs-b": 1}},
+		{name: "should resolve kind without version.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "*", Kind: "ReplicaSet.apps", Name: "*", Desired: 4},
+		}, want: map[string]int32{"rs-a": 4, "rs-b": 4}},
+		{name: "should apply filter on scalable workloads.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "default", Kind: "ReplicaSet.v1.apps", Name: "*", Desired: 0, Filter: "workload.spec.replicas > 2"},
+		}, want: map[string]int32{"rs-a": 0, "rs-b": 1}},
+		{name: "should skip unknown kinds.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "default", Kind: "Rollout.v1alpha1.argoproj.io", Name: "*", Desired: 0},
+		}, want: map[string]int32{"rs-a": 3, "rs-b": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(
+				&apps.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rs-a"}, Spec: apps.ReplicaSetSpec{Replicas: pointer.Int32(3)}},
+				&apps.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rs-b"}, Spec: apps.ReplicaSetSpec{Replicas: pointer.Int32(1)}},
+			)
+			w := New()
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			for name, want := range tt.want {
+				replicaSet := &apps.ReplicaSet{}
+				if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, replicaSet); err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if *replicaSet.Spec.Replicas != want {
+					t.Errorf("executeAction() %s replicas = %v, want %v", name, *replicaSet.Spec.Replicas, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_executeAction(t *testing.T) {
+	tests := []struct {
+		name              string
+		objs              []client.Object
+		workloadSchedules []v1.WorkloadScheduleData
+		want              map[string]int32
+	}{
+		{name: "should adjust replicas of matching deployments.", objs: []client.Object{
+			newTestDeployment("default", "app-a", 3, nil),
+			newTestDeployment("default", "app-b", 1, nil),
+		}, workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "default", Kind: "deployment", Name: "app-a", Desired: 0},
+		}, want: map[string]int32{"app-a": 0, "app-b": 1}},
+		{name: "should only adjust deployments matching the filter.", objs: []client.Object{
+			newTestDeployment("default", "app-a", 3, nil),
+			newTestDeployment("default", "app-b", 1, nil),
+		}, workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "default", Kind: "deployment", Name: "*", Desired: 0, Filter: "workload.spec.replicas > 2"},
+		}, want: map[string]int32{"app-a": 0, "app-b": 1}},
+		{name: "should let a lower ranked workload schedule act on deployments filtered out by a higher ranked one.", objs: []client.Object{
+			newTestDeployment("default", "app-a", 3, nil),
+			newTestDeployment("default", "app-b", 1, nil),
+		}, workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws-1", Namespace: "default", Kind: "deployment", Name: "*", Desired: 0, Filter: "workload.spec.replicas > 2"},
+			{WorkloadScheduler: "ws-2", Namespace: "*", Kind: "deployment", Name: "*", Desired: 2},
+		}, want: map[string]int32{"app-a": 0, "app-b": 2}},
+		{name: "should skip workload schedule with an invalid filter.", objs: []client.Object{
+			newTestDeployment("default", "app-a", 3, nil),
+		}, workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "default", Kind: "deployment", Name: "*", Desired: 0, Filter: "workload.spec.replicas >"},
+		}, want: map[string]int32{"app-a": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(tt.objs...)
+			w := New()
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			for name, want := range tt.want {
+				deployment := &apps.Deployment{}
+				if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, deployment); err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if *deployment.Spec.Replicas != want {
+					t.Errorf("executeAction() %s replicas = %v, want %v", name, *deployment.Spec.Replicas, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_extractSchedulesOfInstant(t *testing.T) {
+	active := v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "always"}, Spec: v1.ScheduleSpec{ScheduleUnits: []v1.ScheduleUnit{{Start: v1.TimeUnit{Date: "2000-01-01"}, End: v1.TimeUnit{Date: "2999-12-31"}}}}}
+	activeStart, _ := util.ProcessScheduleTimeUnit(active.Spec.ScheduleUnits[0].Start, time.Now())
+	inactive := v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "past"}, Spec: v1.ScheduleSpec{ScheduleUnits: []v1.ScheduleUnit{{Start: v1.TimeUnit{Date: "2000-01-01"}, End: v1.TimeUnit{Date: "2000-01-02"}}}}}
+	newWorkloadSchedule := func(defaultDesired *int32) v1.WorkloadSchedule {
+		return v1.WorkloadSchedule{ObjectMeta: metav1.ObjectMeta{Name: "ws"}, Spec: v1.WorkloadScheduleSpec{
+			Selector:       v1.WorkloadSelector{Kinds: []string{"deployment"}},
+			Schedules:      []v1.WorkloadScheduleUnit{{Schedule: "always", Desired: 3}, {Schedule: "past", Desired: 1}},
+			DefaultDesired: defaultDesired}}
+	}
+	tests := []struct {
+		name             string
+		schedules        []v1.Schedule
+		workloadSchedule v1.WorkloadSchedule
+		want             map[string]map[string][]v1.WorkloadScheduleData
+	}{
+		{name: "should use active schedule over default desired.", schedules: []v1.Schedule{active, inactive}, workloadSchedule: newWorkloadSchedule(pointer.Int32(2)),
+			want: map[string]map[string][]v1.WorkloadScheduleData{"0100": {"*/deployment/*": {{WorkloadScheduler: "ws", Namespace: "*", Kind: "deployment", Name: "*", Desired: 3, StartTime: &metav1.Time{Time: activeStart}}}}}},
+		{name: "should use default desired when no schedule is active.", schedules: []v1.Schedule{inactive}, workloadSchedule: newWorkloadSchedule(pointer.Int32(2)),
+			want: map[string]map[string][]v1.WorkloadScheduleData{"0100": {"*/deployment/*": {{WorkloadScheduler: "ws", Namespace: "*", Kind: "deployment", Name: "*", Desired: 2}}}}},
+		{name: "should drop workload schedule without default desired when no schedule is active.", schedules: []v1.Schedule{inactive}, workloadSchedule: newWorkloadSchedule(nil),
+			want: map[string]map[string][]v1.WorkloadScheduleData{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New()
+			got := w.extractSchedulesOfInstant(map[string][]v1.Schedule{"ws": tt.schedules}, map[string]v1.WorkloadSchedule{"ws": tt.workloadSchedule})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractSchedulesOfInstant() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_getRampDesired(t *testing.T) {
+	ramp := v1.Ramp{From: 2, To: 30, Start: v1.TimeUnit{Time: "07:30:00"}, End: v1.TimeUnit{Time: "08:30:00"}}
+	rampDown := v1.Ramp{From: 30, To: 2, Start: v1.TimeUnit{Time: "18:00:00"}, End: v1.TimeUnit{Time: "19:00:00"}}
+	today := func(clock string) time.Time {
+		now, _ := time.ParseInLocation(time.DateTime, "2023-06-01 "+clock, time.Local)
+		return now
+	}
+	tests := []struct {
+		name string
+		ramp v1.Ramp
+		now  time.Time
+		want int32
+	}{
+		{name: "should return from before the ramp starts.", ramp: ramp, now: today("07:00:00"), want: 2},
+		{name: "should interpolate halfway through the ramp.", ramp: ramp, now: today("08:00:00"), want: 16},
+		{name: "should round the interpolated value.", ramp: ramp, now: today("07:40:00"), want: 7},
+		{name: "should return to after the ramp ends.", ramp: ramp, now: today("09:00:00"), want: 30},
+		{name: "should interpolate ramps down.", ramp: rampDown, now: today("18:15:00"), want: 23},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New()
+			got, err := w.getRampDesired(tt.ramp, tt.now)
+			if err != nil {
+				t.Fatalf("getRampDesired() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("getRampDesired() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_BuildSpecMap_ramp(t *testing.T) {
+	w := &WorkloadScheduleHandler{}
+	specMap := map[string]map[string][]v1.WorkloadScheduleData{}
+	workloadSchedule := v1.WorkloadSchedule{ObjectMeta: metav1.ObjectMeta{Name: "ws"}, Spec: v1.WorkloadScheduleSpec{
+		Schedules: []v1.WorkloadScheduleUnit{{Schedule: "daily", Ramp: &v1.Ramp{From: 0, To: 10, Start: v1.TimeUnit{Time: "08:00:00"}, End: v1.TimeUnit{Time: "10:00:00"}}}},
+	}}
+	now := time.Date(2023, 6, 1, 9, 0, 0, 0, time.Local)
+
+	w.BuildSpecMap(workloadSchedule, specMap, v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "daily"}}, now.Add(-time.Hour), now)
+	if data := specMap["0100"]["*/deployment/*"]; len(data) != 1 || data[0].Desired != 5 {
+		t.Errorf("BuildSpecMap() got = %v, want desired 5 half way through the ramp", data)
+	}
+}
+
+func TestWorkloadScheduleHandler_BuildSpecMap_compilesExpressionsOnce(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantData bool
+	}{
+		{name: "should share the programs compiled for the workload schedule.", filter: "workload.spec.replicas > 2", wantData: true},
+		{name: "should skip workload schedule with invalid filter.", filter: "workload.spec.replicas >"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &WorkloadScheduleHandler{}
+			specMap := map[string]map[string][]v1.WorkloadScheduleData{}
+			workloadSchedule := v1.WorkloadSchedule{ObjectMeta: metav1.ObjectMeta{Name: "ws"}, Spec: v1.WorkloadScheduleSpec{
+				Selector:  v1.WorkloadSelector{Namespaces: []string{"dev", "qa"}, Filter: tt.filter},
+				Schedules: []v1.WorkloadScheduleUnit{{Schedule: "nightly", DesiredExpression: "current * 2"}},
+			}}
+
+			w.BuildSpecMap(workloadSchedule, specMap, v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "nightly"}}, time.Time{}, time.Now())
+			dev, qa := specMap["1100"]["dev/deployment/*"], specMap["1100"]["qa/deployment/*"]
+			if !tt.wantData {
+				if len(specMap) != 0 {
+					t.Errorf("BuildSpecMap() got = %v, want none", specMap)
+				}
+				return
+			}
+			if len(dev) != 1 || len(qa) != 1 {
+				t.Fatalf("BuildSpecMap() got = %v, want data for dev and qa", specMap)
+			}
+			devFilter, devDesired, err := compileExpressions(dev[0])
+			if err != nil {
+				t.Fatalf("compileExpressions() error = %v", err)
+			}
+			qaFilter, qaDesired, err := compileExpressions(qa[0])
+			if err != nil {
+				t.Fatalf("compileExpressions() error = %v", err)
+			}
+			if devFilter == nil || devFilter != qaFilter {
+				t.Errorf("compileExpressions() filter programs = %v, %v, want one shared program", devFilter, qaFilter)
+			}
+			if devDesired == nil || devDesired != qaDesired {
+				t.Errorf("compileExpressions() desired programs = %v, %v, want one shared program", devDesired, qaDesired)
+			}
+		})
+	}
+}
+
+func Test_programCache_prune(t *testing.T) {
+	cache := &programCache{}
+	compiled := 0
+	compile := func(source string) (cel.Program, error) {
+		compiled++
+		return expression.CompileDesired(source)
+	}
+	for _, source := range []string{"current * 2", "baseline", "current * 2"} {
+		if _, err := cache.get(source, compile); err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+	}
+	cache.prune()
+	if _, err := cache.get("baseline", compile); err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	cache.prune()
+	if compiled != 2 {
+		t.Errorf("get() compiled = %d times, want 2", compiled)
+	}
+	if _, ok := cache.compiled["current * 2"]; ok || len(cache.compiled) != 1 {
+		t.Errorf("prune() compiled = %v, want only baseline kept", cache.compiled)
+	}
+}
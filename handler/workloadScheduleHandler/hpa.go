@@ -0,0 +1,196 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	autoscaling "k8s.io/api/autoscaling/v2"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
+)
+
+const horizontalPodAutoscalerKind = "horizontalpodautoscaler"
+
+// hpaBounds are the bounds of a HorizontalPodAutoscaler before a workload schedule adjusted them.
+type hpaBounds struct {
+	WorkloadSchedule string                   `json:"workloadSchedule"`
+	MinReplicas      *int32                   `json:"minReplicas,omitempty"`
+	MaxReplicas      int32                    `json:"maxReplicas"`
+	Metrics          []autoscaling.MetricSpec `json:"metrics,omitempty"`
+}
+
+// getScaleTargetKey returns the key of a scale target by its kind, group and name.
+func getScaleTargetKey(kind string, group string, name string) string {
+	return fmt.Sprintf("%s.%s/%s", kind, group, name)
+}
+
+// findHorizontalPodAutoscaler returns the HorizontalPodAutoscaler whose scale target is workload, nil when there is none.
+// The HorizontalPodAutoscalers of a namespace are listed once per run and indexed by their scale target.
+func findHorizontalPodAutoscaler(ctx context.Context, r client.Client, pass *actionPass, workload client.Object) (*autoscaling.HorizontalPodAutoscaler, error) {
+	gvk, err := apiutil.GVKForObject(workload, r.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	horizontalPodAutoscalers, ok := pass.horizontalPodAutoscalers[workload.GetNamespace()]
+	if !ok {
+		var horizontalPodAutoscalerList autoscaling.HorizontalPodAutoscalerList
+		if err = r.List(ctx, &horizontalPodAutoscalerList, client.InNamespace(workload.GetNamespace())); err != nil {
+			return nil, err
+		}
+		horizontalPodAutoscalers = make(map[string]*autoscaling.HorizontalPodAutoscaler)
+		for idx := range horizontalPodAutoscalerList.Items {
+			targetRef := horizontalPodAutoscalerList.Items[idx].Spec.ScaleTargetRef
+			targetGroupVersion, err := schema.ParseGroupVersion(targetRef.APIVersion)
+			if err != nil {
+				continue
+			}
+			horizontalPodAutoscalers[getScaleTargetKey(targetRef.Kind, targetGroupVersion.Group, targetRef.Name)] = &horizontalPodAutoscalerList.Items[idx]
+		}
+		pass.horizontalPodAutoscalers[workload.GetNamespace()] = horizontalPodAutoscalers
+	}
+	return horizontalPodAutoscalers[getScaleTargetKey(gvk.Kind, gvk.Group, workload.GetName())], nil
+}
+
+// adjustHorizontalPodAutoscaler sets the bounds of the HorizontalPodAutoscaler targeting workload for desired replicas,
+// it returns false when no HorizontalPodAutoscaler targets the workload.
+func (w *WorkloadScheduleHandler) adjustHorizontalPodAutoscaler(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, workload client.Object, desired int32) bool {
+	horizontalPodAutoscaler, err := findHorizontalPodAutoscaler(ctx, r, pass, workload)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to find horizontalpodautoscaler of %s/%s for workloadschedule %s.", workload.GetNamespace(), workload.GetName(), _workloadSchedule.WorkloadScheduler))
+		return false
+	}
+	if horizontalPodAutoscaler == nil {
+		return false
+	}
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", horizontalPodAutoscaler.Namespace, horizontalPodAutoscalerKind, horizontalPodAutoscaler.Name)
+	pass.processedWorkloads[processedWorkloadKey] = processedWorkloadKey
+	w.recordKind(r, ctx, horizontalPodAutoscalerKindsKey, kind)
+
+	bounds, recorded, err := getOriginalHPABounds(horizontalPodAutoscaler)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to read original bounds of horizontalpodautoscaler %s/%s.", horizontalPodAutoscaler.Namespace, horizontalPodAutoscaler.Name))
+		return true
+	}
+	if !recorded {
+		bounds = hpaBounds{MinReplicas: horizontalPodAutoscaler.Spec.MinReplicas, MaxReplicas: horizontalPodAutoscaler.Spec.MaxReplicas, Metrics: horizontalPodAutoscaler.Spec.Metrics}
+	}
+	bounds.WorkloadSchedule = _workloadSchedule.WorkloadScheduler
+	originalBounds, err := json.Marshal(bounds)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to record original bounds of horizontalpodautoscaler %s/%s.", horizontalPodAutoscaler.Namespace, horizontalPodAutoscaler.Name))
+		return true
+	}
+
+	updated := horizontalPodAutoscaler.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	updated.Annotations[util.OriginalHPABoundsAnnotation] = string(originalBounds)
+	updated.Spec.MinReplicas = &desired
+	updated.Spec.MaxReplicas = bounds.MaxReplicas
+	updated.Spec.Metrics = bounds.Metrics
+	if _workloadSchedule.HPA != nil {
+		if _workloadSchedule.HPA.MaxReplicas != nil {
+			updated.Spec.MaxReplicas = *_workloadSchedule.HPA.MaxReplicas
+		}
+		if _workloadSchedule.HPA.TargetCPUUtilizationPercentage != nil {
+			updated.Spec.Metrics = withCPUUtilizationTarget(bounds.Metrics, *_workloadSchedule.HPA.TargetCPUUtilizationPercentage)
+		}
+	}
+	if updated.Spec.MaxReplicas < desired {
+		updated.Spec.MaxReplicas = desired
+	}
+
+	if reflect.DeepEqual(horizontalPodAutoscaler, updated) {
+		log.Log.Info(fmt.Sprintf("got horizontalpodautoscaler %s in order with %s. Namespace: %s, MinReplicas: %d", updated.Name, _workloadSchedule.WorkloadScheduler, updated.Namespace, desired))
+		return true
+	}
+	workloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	if w.isDeferred(_workloadSchedule, pass, workload, workloadKey, fmt.Sprintf("update of horizontalpodautoscaler %s", updated.Name)) {
+		return true
+	}
+	if err = r.Update(ctx, updated); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to update horizontalpodautoscaler %s/%s for workloadschedule %s.", updated.Namespace, updated.Name, _workloadSchedule.WorkloadScheduler))
+	} else {
+		log.Log.Info(fmt.Sprintf("%v updated horizontalpodautoscaler NS: %v, Name: %v, minReplicas: %v, maxReplicas: %v", _workloadSchedule.WorkloadScheduler, updated.Namespace, updated.Name, desired, updated.Spec.MaxReplicas))
+		pass.countChange(workload, workloadKey)
+	}
+	return true
+}
+
+// restoreHorizontalPodAutoscalers restores the original bounds of HorizontalPodAutoscalers no workload schedule adjusted in this run,
+// HorizontalPodAutoscalers are only listed once the bounds of one were adjusted.
+func (w *WorkloadScheduleHandler) restoreHorizontalPodAutoscalers(r client.Client, ctx context.Context, pass *actionPass) {
+	if len(w.getTrackedKinds(horizontalPodAutoscalerKindsKey)) == 0 {
+		return
+	}
+	var horizontalPodAutoscalers autoscaling.HorizontalPodAutoscalerList
+	if err := r.List(ctx, &horizontalPodAutoscalers); err != nil {
+		log.Log.Error(err, "error occurred when fetching horizontalpodautoscalers")
+		return
+	}
+	for idx := range horizontalPodAutoscalers.Items {
+		horizontalPodAutoscaler := &horizontalPodAutoscalers.Items[idx]
+		if !pass.isRestorable(fmt.Sprintf("%s/%s/%s", horizontalPodAutoscaler.Namespace, horizontalPodAutoscalerKind, horizontalPodAutoscaler.Name), strings.ToLower(horizontalPodAutoscaler.Spec.ScaleTargetRef.Kind)) {
+			continue
+		}
+		bounds, recorded, err := getOriginalHPABounds(horizontalPodAutoscaler)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to read original bounds of horizontalpodautoscaler %s/%s.", horizontalPodAutoscaler.Namespace, horizontalPodAutoscaler.Name))
+			continue
+		}
+		if !recorded {
+			continue
+		}
+		workloadKey := fmt.Sprintf("%s/%s/%s", horizontalPodAutoscaler.Namespace, strings.ToLower(horizontalPodAutoscaler.Spec.ScaleTargetRef.Kind), horizontalPodAutoscaler.Spec.ScaleTargetRef.Name)
+		if w.isRestoreDeferred(pass, horizontalPodAutoscaler, workloadKey) {
+			continue
+		}
+		delete(horizontalPodAutoscaler.Annotations, util.OriginalHPABoundsAnnotation)
+		horizontalPodAutoscaler.Spec.MinReplicas = bounds.MinReplicas
+		horizontalPodAutoscaler.Spec.MaxReplicas = bounds.MaxReplicas
+		horizontalPodAutoscaler.Spec.Metrics = bounds.Metrics
+		if err = r.Update(ctx, horizontalPodAutoscaler); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to restore horizontalpodautoscaler %s/%s adjusted by workloadschedule %s.", horizontalPodAutoscaler.Namespace, horizontalPodAutoscaler.Name, bounds.WorkloadSchedule))
+		} else {
+			log.Log.Info(fmt.Sprintf("restored horizontalpodautoscaler NS: %v, Name: %v adjusted by %v", horizontalPodAutoscaler.Namespace, horizontalPodAutoscaler.Name, bounds.WorkloadSchedule))
+			pass.countChange(horizontalPodAutoscaler, workloadKey)
+		}
+	}
+}
+
+func getOriginalHPABounds(horizontalPodAutoscaler *autoscaling.HorizontalPodAutoscaler) (hpaBounds, bool, error) {
+	var bounds hpaBounds
+	originalBounds, ok := horizontalPodAutoscaler.Annotations[util.OriginalHPABoundsAnnotation]
+	if !ok {
+		return bounds, false, nil
+	}
+	err := json.Unmarshal([]byte(originalBounds), &bounds)
+	return bounds, err == nil, err
+}
+
+// withCPUUtilizationTarget returns a copy of metrics with the average cpu utilization target set, adding the metric when missing.
+func withCPUUtilizationTarget(metrics []autoscaling.MetricSpec, targetCPUUtilizationPercentage int32) []autoscaling.MetricSpec {
+	var _metrics []autoscaling.MetricSpec
+	found := false
+	for _, metric := range metrics {
+		metric = *metric.DeepCopy()
+		if metric.Type == autoscaling.ResourceMetricSourceType && metric.Resource != nil && metric.Resource.Name == core.ResourceCPU {
+			metric.Resource.Target = autoscaling.MetricTarget{Type: autoscaling.UtilizationMetricType, AverageUtilization: &targetCPUUtilizationPercentage}
+			found = true
+		}
+		_metrics = append(_metrics, metric)
+	}
+	if !found {
+		_metrics = append(_metrics, autoscaling.MetricSpec{Type: autoscaling.ResourceMetricSourceType, Resource: &autoscaling.ResourceMetricSource{
+			Name: core.ResourceCPU, Target: autoscaling.MetricTarget{Type: autoscaling.UtilizationMetricType, AverageUtilization: &targetCPUUtilizationPercentage}}})
+	}
+	return _metrics
+}
@@ -0,0 +1,147 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"testing"
+)
+
+func newTestHorizontalPodAutoscaler(annotations map[string]string, minReplicas int32, maxReplicas int32) *autoscaling.HorizontalPodAutoscaler {
+	return &autoscaling.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "api", Annotations: annotations},
+		Spec: autoscaling.HorizontalPodAutoscalerSpec{ScaleTargetRef: autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+			MinReplicas: pointer.Int32(minReplicas), MaxReplicas: maxReplicas}}
+}
+
+func TestWorkloadScheduleHandler_adjustHorizontalPodAutoscaler(t *testing.T) {
+	cpuTarget := []autoscaling.MetricSpec{{Type: autoscaling.ResourceMetricSourceType, Resource: &autoscaling.ResourceMetricSource{
+		Name: core.ResourceCPU, Target: autoscaling.MetricTarget{Type: autoscaling.UtilizationMetricType, AverageUtilization: pointer.Int32(50)}}}}
+	adjusted := map[string]string{util.OriginalHPABoundsAnnotation: `{"workloadSchedule":"ws","minReplicas":2,"maxReplicas":10}`}
+	tests := []struct {
+		name              string
+		deployment        *apps.Deployment
+		hpa               *autoscaling.HorizontalPodAutoscaler
+		workloadSchedules []v1.WorkloadScheduleData
+		wantReplicas      int32
+		wantMinReplicas   int32
+		wantMaxReplicas   int32
+		wantMetrics       []autoscaling.MetricSpec
+		wantAnnotation    bool
+		// recorded is set when an earlier run recorded the kind of the workload as adjusted.
+		recorded bool
+	}{
+		{name: "should set minReplicas of hpa instead of replicas.", deployment: newTestDeployment("dev", "api", 2, nil), hpa: newTestHorizontalPodAutoscaler(nil, 2, 10),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 5, HPAPolicy: v1.HPAPolicyAdjustBounds}},
+			wantReplicas:      2, wantMinReplicas: 5, wantMaxReplicas: 10, wantAnnotation: true},
+		{name: "should apply hpa overrides and raise maxReplicas to desired.", deployment: newTestDeployment("dev", "api", 2, nil), hpa: newTestHorizontalPodAutoscaler(nil, 2, 10),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 6, HPAPolicy: v1.HPAPolicyAdjustBounds,
+				HPA: &v1.HPAOverride{MaxReplicas: pointer.Int32(4), TargetCPUUtilizationPercentage: pointer.Int32(50)}}},
+			wantReplicas: 2, wantMinReplicas: 6, wantMaxReplicas: 6, wantMetrics: cpuTarget, wantAnnotation: true},
+		{name: "should scale workload to 0 and leave hpa untouched.", deployment: newTestDeployment("dev", "api", 2, nil), hpa: newTestHorizontalPodAutoscaler(nil, 2, 10),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 0, HPAPolicy: v1.HPAPolicyAdjustBounds}},
+			wantReplicas:      0, wantMinReplicas: 2, wantMaxReplicas: 10},
+		{name: "should wake workload at 0 and set minReplicas of hpa.", deployment: newTestDeployment("dev", "api", 0, nil), hpa: newTestHorizontalPodAutoscaler(nil, 2, 10),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 3, HPAPolicy: v1.HPAPolicyAdjustBounds}},
+			wantReplicas:      3, wantMinReplicas: 3, wantMaxReplicas: 10, wantAnnotation: true},
+		{name: "should set replicas when hpa policy is replicas.", deployment: newTestDeployment("dev", "api", 2, nil), hpa: newTestHorizontalPodAutoscaler(nil, 2, 10),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 5}},
+			wantReplicas:      5, wantMinReplicas: 2, wantMaxReplicas: 10},
+		{name: "should restore original bounds when no schedule applies.", deployment: newTestDeployment("dev", "api", 5, nil), hpa: newTestHorizontalPodAutoscaler(adjusted, 5, 10),
+			wantReplicas: 5, wantMinReplicas: 2, wantMaxReplicas: 10, recorded: true},
+		{name: "should not restore bounds when no adjusted kind is recorded.", deployment: newTestDeployment("dev", "api", 5, nil), hpa: newTestHorizontalPodAutoscaler(adjusted, 5, 10),
+			wantReplicas: 5, wantMinReplicas: 5, wantMaxReplicas: 10, wantAnnotation: true},
+		{name: "should keep original bounds when adjusted again.", deployment: newTestDeployment("dev", "api", 5, nil), hpa: newTestHorizontalPodAutoscaler(adjusted, 5, 10),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 4, HPAPolicy: v1.HPAPolicyAdjustBounds}},
+			wantReplicas:      5, wantMinReplicas: 4, wantMaxReplicas: 10, wantAnnotation: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(tt.deployment, tt.hpa)
+			w := New()
+			if tt.recorded {
+				w.trackKind(horizontalPodAutoscalerKindsKey, util.DEPLOYMENT)
+			}
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			deployment := &apps.Deployment{}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(tt.deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *deployment.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, tt.wantReplicas)
+			}
+			hpa := &autoscaling.HorizontalPodAutoscaler{}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(tt.hpa), hpa); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *hpa.Spec.MinReplicas != tt.wantMinReplicas || hpa.Spec.MaxReplicas != tt.wantMaxReplicas {
+				t.Errorf("executeAction() hpa bounds = %v-%v, want %v-%v", *hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas, tt.wantMinReplicas, tt.wantMaxReplicas)
+			}
+			if !reflect.DeepEqual(hpa.Spec.Metrics, tt.wantMetrics) {
+				t.Errorf("executeAction() hpa metrics = %v, want %v", hpa.Spec.Metrics, tt.wantMetrics)
+			}
+			if _, got := hpa.Annotations[util.OriginalHPABoundsAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+type countingListClient struct {
+	client.Client
+	lists map[string]int
+}
+
+func (c *countingListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if gvk, err := apiutil.GVKForObject(list, c.Scheme()); err == nil {
+		c.lists[gvk.Kind]++
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestWorkloadScheduleHandler_findHorizontalPodAutoscaler_listsOncePerNamespace(t *testing.T) {
+	web := newTestHorizontalPodAutoscaler(nil, 2, 10)
+	web.Name, web.Spec.ScaleTargetRef.Name = "web", "web"
+	c := &countingListClient{Client: newTestClient(newTestDeployment("dev", "api", 2, nil), newTestDeployment("dev", "web", 2, nil), newTestHorizontalPodAutoscaler(nil, 2, 10), web), lists: make(map[string]int)}
+	w := New()
+	_, err := w.executeAction([]v1.WorkloadScheduleData{
+		{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 4, HPAPolicy: v1.HPAPolicyAdjustBounds},
+		{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "web", Desired: 5, HPAPolicy: v1.HPAPolicyAdjustBounds},
+	}, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	for name, wantMinReplicas := range map[string]int32{"api": 4, "web": 5} {
+		hpa := &autoscaling.HorizontalPodAutoscaler{}
+		if err = c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: name}, hpa); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if *hpa.Spec.MinReplicas != wantMinReplicas {
+			t.Errorf("executeAction() minReplicas of %s = %v, want %v", name, *hpa.Spec.MinReplicas, wantMinReplicas)
+		}
+	}
+	// one list finds the horizontalpodautoscalers of the namespace, one more restores those left unadjusted.
+	if got := c.lists["HorizontalPodAutoscalerList"]; got != 2 {
+		t.Errorf("executeAction() listed horizontalpodautoscalers %d times, want 2", got)
+	}
+}
+
+func TestWorkloadScheduleHandler_restoreHorizontalPodAutoscalers_unrecorded(t *testing.T) {
+	c := &countingListClient{Client: newTestClient(newTestDeployment("dev", "api", 2, nil), newTestHorizontalPodAutoscaler(nil, 2, 10)), lists: make(map[string]int)}
+	w := New()
+	if _, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 3}}, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if got := c.lists["HorizontalPodAutoscalerList"]; got != 0 {
+		t.Errorf("executeAction() listed horizontalpodautoscalers %d times, want none until bounds are adjusted", got)
+	}
+}
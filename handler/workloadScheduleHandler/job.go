@@ -0,0 +1,79 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"fmt"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// JobHandler pauses jobs through spec.suspend when desired is 0 and resumes them when desired is above 0.
+//
+// Finished jobs are skipped, only jobs suspended by the operator are resumed.
+type JobHandler struct {
+	DesiredComputer
+}
+
+func NewJobHandler() *JobHandler {
+	return &JobHandler{}
+}
+
+func (j *JobHandler) NewWorkload() client.Object {
+	return &batch.Job{}
+}
+
+func (j *JobHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var jobWorkloads batch.JobList
+	if err := r.List(ctx, &jobWorkloads, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range jobWorkloads.Items {
+		if isJobFinished(&jobWorkloads.Items[idx]) {
+			continue
+		}
+		workloads = append(workloads, &jobWorkloads.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (j *JobHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	return suspendToReplicas(workload.(*batch.Job).Spec.Suspend), nil
+}
+
+func (j *JobHandler) ComputeDesired(ctx context.Context, r client.Client, workload client.Object, current int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error) {
+	desired, err := j.DesiredComputer.ComputeDesired(ctx, r, workload, current, _workloadSchedule)
+	if err != nil {
+		return 0, err
+	}
+	return suspendDesired(workload, current, desired), nil
+}
+
+func (j *JobHandler) TogglesState() bool {
+	return true
+}
+
+func (j *JobHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	job := workload.(*batch.Job)
+	job.Spec.Suspend = applySuspend(job, desired)
+	return r.Update(ctx, job)
+}
+
+// ReportStatus lists the job as paused on the workload schedule when the operator suspended it.
+func (j *JobHandler) ReportStatus(workload client.Object, desired int32, status *workloadschedulerv1.WorkloadScheduleStatus) {
+	if _, ok := workload.GetAnnotations()[util.SuspendedAnnotation]; desired == 0 && ok {
+		status.PausedJobs = append(status.PausedJobs, fmt.Sprintf("%s/%s", workload.GetNamespace(), workload.GetName()))
+	}
+}
+
+func isJobFinished(job *batch.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batch.JobComplete || condition.Type == batch.JobFailed) && condition.Status == core.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestJobHandler(t *testing.T) {
+	completed := batch.JobStatus{Conditions: []batch.JobCondition{{Type: batch.JobComplete, Status: core.ConditionTrue}}}
+	tests := []struct {
+		name           string
+		job            *batch.Job
+		desired        int32
+		wantSuspend    bool
+		wantAnnotation bool
+		wantPausedJobs []string
+	}{
+		{name: "should suspend running job and report it as paused.", job: &batch.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "migrate"}},
+			desired: 0, wantSuspend: true, wantAnnotation: true, wantPausedJobs: []string{"dev/migrate"}},
+		{name: "should resume job suspended by the operator.", job: &batch.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "migrate", Annotations: map[string]string{util.SuspendedAnnotation: "true"}}, Spec: batch.JobSpec{Suspend: pointer.Bool(true)}},
+			desired: 1, wantSuspend: false, wantAnnotation: false},
+		{name: "should not resume job suspended by its owner.", job: &batch.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "migrate"}, Spec: batch.JobSpec{Suspend: pointer.Bool(true)}},
+			desired: 1, wantSuspend: true, wantAnnotation: false},
+		{name: "should skip completed job.", job: &batch.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "migrate"}, Status: completed},
+			desired: 0, wantSuspend: false, wantAnnotation: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(tt.job)
+			w := New()
+			statuses, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.JOB, Name: "migrate", Desired: tt.desired}}, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			job := &batch.Job{}
+			if err = c.Get(context.Background(), client.ObjectKeyFromObject(tt.job), job); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := job.Spec.Suspend != nil && *job.Spec.Suspend; got != tt.wantSuspend {
+				t.Errorf("executeAction() suspend = %v, want %v", got, tt.wantSuspend)
+			}
+			if _, got := job.Annotations[util.SuspendedAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+			var gotPausedJobs []string
+			if status, ok := statuses["ws"]; ok {
+				gotPausedJobs = status.PausedJobs
+			}
+			if !reflect.DeepEqual(gotPausedJobs, tt.wantPausedJobs) {
+				t.Errorf("executeAction() pausedJobs = %v, want %v", gotPausedJobs, tt.wantPausedJobs)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_updateStatuses(t *testing.T) {
+	workloadSchedule := &v1.WorkloadSchedule{ObjectMeta: metav1.ObjectMeta{Name: "ws"}, Status: v1.WorkloadScheduleStatus{PausedJobs: []string{"dev/stale"}}}
+	c := newTestClient(workloadSchedule)
+	w := New()
+
+	statuses := map[string]*v1.WorkloadScheduleStatus{"ws": {PausedJobs: []string{"dev/migrate", "dev/backfill"}}}
+	w.updateStatuses(statuses, map[string]v1.WorkloadSchedule{"ws": *workloadSchedule}, c, context.Background())
+
+	got := &v1.WorkloadSchedule{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(workloadSchedule), got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if want := []string{"dev/backfill", "dev/migrate"}; !reflect.DeepEqual(got.Status.PausedJobs, want) {
+		t.Errorf("updateStatuses() pausedJobs = %v, want %v", got.Status.PausedJobs, want)
+	}
+}
@@ -0,0 +1,249 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+	"strings"
+)
+
+const (
+	scaledObjectKind = "scaledobject"
+	// kedaPausedReplicasAnnotation makes KEDA scale the target to the given replicas and stop autoscaling it.
+	kedaPausedReplicasAnnotation = "autoscaling.keda.sh/paused-replicas"
+)
+
+var scaledObjectGVK = schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "ScaledObject"}
+
+// scaledObjectBounds are the replica bounds and pause annotation of a ScaledObject before a workload schedule adjusted them.
+type scaledObjectBounds struct {
+	WorkloadSchedule string  `json:"workloadSchedule"`
+	MinReplicaCount  *int64  `json:"minReplicaCount,omitempty"`
+	MaxReplicaCount  *int64  `json:"maxReplicaCount,omitempty"`
+	PausedReplicas   *string `json:"pausedReplicas,omitempty"`
+}
+
+// isKEDAInstalled reports whether the ScaledObject kind is served by the cluster.
+func isKEDAInstalled(r client.Client) (bool, error) {
+	_, err := r.RESTMapper().RESTMapping(scaledObjectGVK.GroupKind(), scaledObjectGVK.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// findScaledObject returns the ScaledObject whose scale target is workload, nil when there is none or KEDA is not installed.
+// The ScaledObjects of a namespace are listed once per run and indexed by their scale target.
+func findScaledObject(ctx context.Context, r client.Client, pass *actionPass, workload client.Object) (*unstructured.Unstructured, error) {
+	if installed, err := isKEDAInstalled(r); !installed {
+		return nil, err
+	}
+	gvk, err := apiutil.GVKForObject(workload, r.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	scaledObjects, ok := pass.scaledObjects[workload.GetNamespace()]
+	if !ok {
+		var scaledObjectList unstructured.UnstructuredList
+		scaledObjectList.SetGroupVersionKind(scaledObjectGVK.GroupVersion().WithKind(scaledObjectGVK.Kind + "List"))
+		if err = r.List(ctx, &scaledObjectList, client.InNamespace(workload.GetNamespace())); err != nil {
+			return nil, err
+		}
+		scaledObjects = make(map[string]*unstructured.Unstructured)
+		for idx := range scaledObjectList.Items {
+			scaleTargetRef, _, _ := unstructured.NestedStringMap(scaledObjectList.Items[idx].Object, "spec", "scaleTargetRef")
+			// KEDA defaults the scale target to an apps/v1 Deployment.
+			targetKind, targetAPIVersion := "Deployment", "apps/v1"
+			if len(scaleTargetRef["kind"]) != 0 {
+				targetKind = scaleTargetRef["kind"]
+			}
+			if len(scaleTargetRef["apiVersion"]) != 0 {
+				targetAPIVersion = scaleTargetRef["apiVersion"]
+			}
+			targetGroupVersion, err := schema.ParseGroupVersion(targetAPIVersion)
+			if err != nil {
+				continue
+			}
+			scaledObjects[getScaleTargetKey(targetKind, targetGroupVersion.Group, scaleTargetRef["name"])] = &scaledObjectList.Items[idx]
+		}
+		pass.scaledObjects[workload.GetNamespace()] = scaledObjects
+	}
+	return scaledObjects[getScaleTargetKey(gvk.Kind, gvk.Group, workload.GetName())], nil
+}
+
+// adjustScaledObject pauses the ScaledObject scaling workload when desired is 0, otherwise it sets its minReplicaCount to desired,
+// it returns false when no ScaledObject scales the workload.
+func (w *WorkloadScheduleHandler) adjustScaledObject(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, workload client.Object, desired int32) bool {
+	scaledObject, err := findScaledObject(ctx, r, pass, workload)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to find scaledobject of %s/%s for workloadschedule %s.", workload.GetNamespace(), workload.GetName(), _workloadSchedule.WorkloadScheduler))
+		return false
+	}
+	if scaledObject == nil {
+		return false
+	}
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", scaledObject.GetNamespace(), scaledObjectKind, scaledObject.GetName())
+	pass.processedWorkloads[processedWorkloadKey] = processedWorkloadKey
+	w.recordKind(r, ctx, scaledObjectKindsKey, kind)
+
+	bounds, recorded, err := getOriginalScaledObjectBounds(scaledObject)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to read original bounds of scaledobject %s/%s.", scaledObject.GetNamespace(), scaledObject.GetName()))
+		return true
+	}
+	if !recorded {
+		bounds = scaledObjectBounds{MinReplicaCount: nestedInt64(scaledObject, "minReplicaCount"), MaxReplicaCount: nestedInt64(scaledObject, "maxReplicaCount")}
+		if pausedReplicas, ok := scaledObject.GetAnnotations()[kedaPausedReplicasAnnotation]; ok {
+			bounds.PausedReplicas = &pausedReplicas
+		}
+	}
+	bounds.WorkloadSchedule = _workloadSchedule.WorkloadScheduler
+	originalBounds, err := json.Marshal(bounds)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to record original bounds of scaledobject %s/%s.", scaledObject.GetNamespace(), scaledObject.GetName()))
+		return true
+	}
+
+	updated := scaledObject.DeepCopy()
+	annotations := updated.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[util.OriginalScaledObjectBoundsAnnotation] = string(originalBounds)
+	setNestedInt64(updated, bounds.MinReplicaCount, "minReplicaCount")
+	setNestedInt64(updated, bounds.MaxReplicaCount, "maxReplicaCount")
+	if desired == 0 {
+		annotations[kedaPausedReplicasAnnotation] = "0"
+	} else {
+		delete(annotations, kedaPausedReplicasAnnotation)
+		minReplicaCount := int64(desired)
+		setNestedInt64(updated, &minReplicaCount, "minReplicaCount")
+		// KEDA defaults maxReplicaCount to 100.
+		if maxReplicaCount := nestedInt64(updated, "maxReplicaCount"); (maxReplicaCount == nil && minReplicaCount > 100) || (maxReplicaCount != nil && *maxReplicaCount < minReplicaCount) {
+			setNestedInt64(updated, &minReplicaCount, "maxReplicaCount")
+		}
+	}
+	updated.SetAnnotations(annotations)
+
+	if equality.Semantic.DeepEqual(scaledObject, updated) {
+		log.Log.Info(fmt.Sprintf("got scaledobject %s in order with %s. Namespace: %s, Desired: %d", updated.GetName(), _workloadSchedule.WorkloadScheduler, updated.GetNamespace(), desired))
+		return true
+	}
+	workloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	if w.isDeferred(_workloadSchedule, pass, workload, workloadKey, fmt.Sprintf("update of scaledobject %s", updated.GetName())) {
+		return true
+	}
+	if err = r.Update(ctx, updated); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to update scaledobject %s/%s for workloadschedule %s.", updated.GetNamespace(), updated.GetName(), _workloadSchedule.WorkloadScheduler))
+	} else {
+		log.Log.Info(fmt.Sprintf("%v updated scaledobject NS: %v, Name: %v, desired: %v", _workloadSchedule.WorkloadScheduler, updated.GetNamespace(), updated.GetName(), desired))
+		pass.countChange(workload, workloadKey)
+	}
+	return true
+}
+
+// restoreScaledObjects restores the original bounds of ScaledObjects no workload schedule adjusted in this run, ScaledObjects
+// are only listed once one was adjusted.
+func (w *WorkloadScheduleHandler) restoreScaledObjects(r client.Client, ctx context.Context, pass *actionPass) {
+	if len(w.getTrackedKinds(scaledObjectKindsKey)) == 0 {
+		return
+	}
+	if installed, err := isKEDAInstalled(r); !installed {
+		if err != nil {
+			log.Log.Error(err, "error occurred when looking up scaledobjects")
+		}
+		return
+	}
+	var scaledObjects unstructured.UnstructuredList
+	scaledObjects.SetGroupVersionKind(scaledObjectGVK.GroupVersion().WithKind(scaledObjectGVK.Kind + "List"))
+	if err := r.List(ctx, &scaledObjects); err != nil {
+		log.Log.Error(err, "error occurred when fetching scaledobjects")
+		return
+	}
+	for idx := range scaledObjects.Items {
+		scaledObject := &scaledObjects.Items[idx]
+		targetKind, _, _ := unstructured.NestedString(scaledObject.Object, "spec", "scaleTargetRef", "kind")
+		if len(targetKind) == 0 {
+			targetKind = util.DEPLOYMENT
+		}
+		if !pass.isRestorable(fmt.Sprintf("%s/%s/%s", scaledObject.GetNamespace(), scaledObjectKind, scaledObject.GetName()), strings.ToLower(targetKind)) {
+			continue
+		}
+		bounds, recorded, err := getOriginalScaledObjectBounds(scaledObject)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to read original bounds of scaledobject %s/%s.", scaledObject.GetNamespace(), scaledObject.GetName()))
+			continue
+		}
+		if !recorded {
+			continue
+		}
+		targetName, _, _ := unstructured.NestedString(scaledObject.Object, "spec", "scaleTargetRef", "name")
+		workloadKey := fmt.Sprintf("%s/%s/%s", scaledObject.GetNamespace(), strings.ToLower(targetKind), targetName)
+		if w.isRestoreDeferred(pass, scaledObject, workloadKey) {
+			continue
+		}
+		annotations := scaledObject.GetAnnotations()
+		delete(annotations, util.OriginalScaledObjectBoundsAnnotation)
+		if bounds.PausedReplicas != nil {
+			annotations[kedaPausedReplicasAnnotation] = *bounds.PausedReplicas
+		} else {
+			delete(annotations, kedaPausedReplicasAnnotation)
+		}
+		scaledObject.SetAnnotations(annotations)
+		setNestedInt64(scaledObject, bounds.MinReplicaCount, "minReplicaCount")
+		setNestedInt64(scaledObject, bounds.MaxReplicaCount, "maxReplicaCount")
+		if err = r.Update(ctx, scaledObject); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to restore scaledobject %s/%s adjusted by workloadschedule %s.", scaledObject.GetNamespace(), scaledObject.GetName(), bounds.WorkloadSchedule))
+		} else {
+			log.Log.Info(fmt.Sprintf("restored scaledobject NS: %v, Name: %v adjusted by %v", scaledObject.GetNamespace(), scaledObject.GetName(), bounds.WorkloadSchedule))
+			pass.countChange(scaledObject, workloadKey)
+		}
+	}
+}
+
+func getOriginalScaledObjectBounds(scaledObject *unstructured.Unstructured) (scaledObjectBounds, bool, error) {
+	var bounds scaledObjectBounds
+	originalBounds, ok := scaledObject.GetAnnotations()[util.OriginalScaledObjectBoundsAnnotation]
+	if !ok {
+		return bounds, false, nil
+	}
+	err := json.Unmarshal([]byte(originalBounds), &bounds)
+	return bounds, err == nil, err
+}
+
+func nestedInt64(scaledObject *unstructured.Unstructured, field string) *int64 {
+	value, found, err := unstructured.NestedFieldNoCopy(scaledObject.Object, "spec", field)
+	if !found || err != nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case int64:
+		return &v
+	case float64:
+		i := int64(v)
+		return &i
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return &i
+		}
+	}
+	return nil
+}
+
+func setNestedInt64(scaledObject *unstructured.Unstructured, value *int64, field string) {
+	if value == nil {
+		unstructured.RemoveNestedField(scaledObject.Object, "spec", field)
+		return
+	}
+	_ = unstructured.SetNestedField(scaledObject.Object, *value, "spec", field)
+}
@@ -0,0 +1,115 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func newTestScaledObject(annotations map[string]string, spec map[string]interface{}) *unstructured.Unstructured {
+	scaledObject := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	scaledObject.SetGroupVersionKind(scaledObjectGVK)
+	scaledObject.SetNamespace("dev")
+	scaledObject.SetName("consumer")
+	scaledObject.SetAnnotations(annotations)
+	return scaledObject
+}
+
+func TestWorkloadScheduleHandler_adjustScaledObject(t *testing.T) {
+	scaleTargetRef := map[string]interface{}{"name": "consumer"}
+	adjusted := map[string]string{kedaPausedReplicasAnnotation: "0", util.OriginalScaledObjectBoundsAnnotation: `{"workloadSchedule":"ws","minReplicaCount":1,"maxReplicaCount":10}`}
+	tests := []struct {
+		name                string
+		scaledObject        *unstructured.Unstructured
+		workloadSchedules   []v1.WorkloadScheduleData
+		wantReplicas        int32
+		wantMinReplicaCount int64
+		wantMaxReplicaCount int64
+		wantPausedReplicas  string
+		wantAnnotation      bool
+		// recorded is set when an earlier run recorded the kind of the workload as adjusted.
+		recorded bool
+	}{
+		{name: "should pause scaledobject when desired is 0.", scaledObject: newTestScaledObject(nil, map[string]interface{}{"scaleTargetRef": scaleTargetRef, "minReplicaCount": int64(1), "maxReplicaCount": int64(10)}),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "consumer", Desired: 0}},
+			wantReplicas:      2, wantMinReplicaCount: 1, wantMaxReplicaCount: 10, wantPausedReplicas: "0", wantAnnotation: true},
+		{name: "should resume scaledobject and override its bounds.", scaledObject: newTestScaledObject(adjusted, map[string]interface{}{"scaleTargetRef": scaleTargetRef, "minReplicaCount": int64(1), "maxReplicaCount": int64(10)}),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "consumer", Desired: 12}},
+			wantReplicas:      2, wantMinReplicaCount: 12, wantMaxReplicaCount: 12, wantAnnotation: true},
+		{name: "should restore scaledobject when no schedule applies.", scaledObject: newTestScaledObject(adjusted, map[string]interface{}{"scaleTargetRef": scaleTargetRef, "minReplicaCount": int64(4), "maxReplicaCount": int64(10)}),
+			wantReplicas: 2, wantMinReplicaCount: 1, wantMaxReplicaCount: 10, recorded: true},
+		{name: "should not restore scaledobject when no adjusted kind is recorded.", scaledObject: newTestScaledObject(adjusted, map[string]interface{}{"scaleTargetRef": scaleTargetRef, "minReplicaCount": int64(4), "maxReplicaCount": int64(10)}),
+			wantReplicas: 2, wantMinReplicaCount: 4, wantMaxReplicaCount: 10, wantPausedReplicas: "0", wantAnnotation: true},
+		{name: "should scale deployment not scaled by the scaledobject.", scaledObject: newTestScaledObject(nil, map[string]interface{}{"scaleTargetRef": map[string]interface{}{"name": "other"}, "minReplicaCount": int64(1), "maxReplicaCount": int64(10)}),
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "consumer", Desired: 0}},
+			wantReplicas:      0, wantMinReplicaCount: 1, wantMaxReplicaCount: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := newTestDeployment("dev", "consumer", 2, nil)
+			c := newTestClient(deployment, tt.scaledObject)
+			w := New()
+			if tt.recorded {
+				w.trackKind(scaledObjectKindsKey, util.DEPLOYMENT)
+			}
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *deployment.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, tt.wantReplicas)
+			}
+			scaledObject := &unstructured.Unstructured{}
+			scaledObject.SetGroupVersionKind(scaledObjectGVK)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(tt.scaledObject), scaledObject); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := *nestedInt64(scaledObject, "minReplicaCount"); got != tt.wantMinReplicaCount {
+				t.Errorf("executeAction() minReplicaCount = %v, want %v", got, tt.wantMinReplicaCount)
+			}
+			if got := *nestedInt64(scaledObject, "maxReplicaCount"); got != tt.wantMaxReplicaCount {
+				t.Errorf("executeAction() maxReplicaCount = %v, want %v", got, tt.wantMaxReplicaCount)
+			}
+			if got := scaledObject.GetAnnotations()[kedaPausedReplicasAnnotation]; got != tt.wantPausedReplicas {
+				t.Errorf("executeAction() paused replicas = %v, want %v", got, tt.wantPausedReplicas)
+			}
+			if _, got := scaledObject.GetAnnotations()[util.OriginalScaledObjectBoundsAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_findScaledObject_listsOncePerNamespace(t *testing.T) {
+	worker := newTestScaledObject(nil, map[string]interface{}{"scaleTargetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "worker"}})
+	worker.SetName("worker")
+	c := &countingListClient{Client: newTestClient(newTestDeployment("dev", "consumer", 2, nil), newTestDeployment("dev", "worker", 2, nil),
+		newTestScaledObject(nil, map[string]interface{}{"scaleTargetRef": map[string]interface{}{"name": "consumer"}}), worker), lists: make(map[string]int)}
+	w := New()
+	_, err := w.executeAction([]v1.WorkloadScheduleData{
+		{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "consumer", Desired: 0},
+		{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "worker", Desired: 0},
+	}, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	for _, name := range []string{"consumer", "worker"} {
+		scaledObject := &unstructured.Unstructured{}
+		scaledObject.SetGroupVersionKind(scaledObjectGVK)
+		if err = c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: name}, scaledObject); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got := scaledObject.GetAnnotations()[kedaPausedReplicasAnnotation]; got != "0" {
+			t.Errorf("executeAction() paused replicas of %s = %v, want 0", name, got)
+		}
+	}
+	// one list finds the scaledobjects of the namespace, one more restores those left unadjusted.
+	if got := c.lists["ScaledObjectList"]; got != 2 {
+		t.Errorf("executeAction() listed scaledobjects %d times, want 2", got)
+	}
+}
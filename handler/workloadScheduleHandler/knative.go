@@ -0,0 +1,188 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+)
+
+const (
+	knativeMinScaleAnnotation = "autoscaling.knative.dev/min-scale"
+	knativeMaxScaleAnnotation = "autoscaling.knative.dev/max-scale"
+)
+
+var knativeServiceGVK = schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1", Kind: "Service"}
+
+// knativeScale are the scale annotations of a knative service revision template before a workload schedule adjusted them.
+type knativeScale struct {
+	MinScale *string `json:"minScale,omitempty"`
+	MaxScale *string `json:"maxScale,omitempty"`
+}
+
+// KnativeServiceHandler sets the min-scale and max-scale annotations of the revision template of knative services,
+// the original annotations are recorded on the service and restored once no workload schedule applies to it.
+type KnativeServiceHandler struct {
+	DesiredComputer
+}
+
+func NewKnativeServiceHandler() *KnativeServiceHandler {
+	return &KnativeServiceHandler{}
+}
+
+func (k *KnativeServiceHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var knativeServices unstructured.UnstructuredList
+	knativeServices.SetGroupVersionKind(knativeServiceGVK.GroupVersion().WithKind(knativeServiceGVK.Kind + "List"))
+	if err := r.List(ctx, &knativeServices, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range knativeServices.Items {
+		workloads = append(workloads, &knativeServices.Items[idx])
+	}
+	return workloads, nil
+}
+
+// GetCurrent returns the min-scale of the revision template, 0 when not set.
+func (k *KnativeServiceHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	minScale, ok := getTemplateAnnotations(workload)[knativeMinScaleAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	current, err := strconv.ParseInt(minScale, 10, 32)
+	return int32(current), err
+}
+
+func (k *KnativeServiceHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	return k.ApplyWorkloadSchedule(ctx, r, workload, desired, workloadschedulerv1.WorkloadScheduleData{Desired: desired})
+}
+
+func (k *KnativeServiceHandler) InOrder(workload client.Object, desired int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) bool {
+	annotations := getTemplateAnnotations(workload)
+	if _, ok := workload.GetAnnotations()[util.OriginalKnativeScaleAnnotation]; !ok {
+		return false
+	}
+	minScale, maxScale := knativeTargetScale(desired, _workloadSchedule)
+	currentMaxScale, ok := annotations[knativeMaxScaleAnnotation]
+	return annotations[knativeMinScaleAnnotation] == minScale && (maxScale == nil || (ok && currentMaxScale == *maxScale))
+}
+
+func (k *KnativeServiceHandler) ApplyWorkloadSchedule(ctx context.Context, r client.Client, workload client.Object, desired int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) error {
+	knativeService := workload.(*unstructured.Unstructured)
+	annotations := getTemplateAnnotations(knativeService)
+	if _, ok := knativeService.GetAnnotations()[util.OriginalKnativeScaleAnnotation]; !ok {
+		var original knativeScale
+		if minScale, ok := annotations[knativeMinScaleAnnotation]; ok {
+			original.MinScale = &minScale
+		}
+		if maxScale, ok := annotations[knativeMaxScaleAnnotation]; ok {
+			original.MaxScale = &maxScale
+		}
+		originalScale, err := json.Marshal(original)
+		if err != nil {
+			return err
+		}
+		setAnnotation(knativeService, util.OriginalKnativeScaleAnnotation, string(originalScale))
+	}
+
+	minScale, maxScale := knativeTargetScale(desired, _workloadSchedule)
+	annotations[knativeMinScaleAnnotation] = minScale
+	if maxScale != nil {
+		annotations[knativeMaxScaleAnnotation] = *maxScale
+	}
+	if err := unstructured.SetNestedStringMap(knativeService.Object, annotations, "spec", "template", "metadata", "annotations"); err != nil {
+		return err
+	}
+	return r.Update(ctx, knativeService)
+}
+
+// RestoreWorkloads restores the scale annotations of knative services adjusted by a workload schedule and not kept in this run.
+func (k *KnativeServiceHandler) RestoreWorkloads(ctx context.Context, r client.Client, isKept func(workload client.Object) bool) error {
+	if _, err := r.RESTMapper().RESTMapping(knativeServiceGVK.GroupKind(), knativeServiceGVK.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+	workloads, err := k.ListWorkloads(ctx, r)
+	if err != nil {
+		return err
+	}
+	for _, workload := range workloads {
+		originalScale, ok := workload.GetAnnotations()[util.OriginalKnativeScaleAnnotation]
+		if !ok || isKept(workload) {
+			continue
+		}
+		var original knativeScale
+		if err = json.Unmarshal([]byte(originalScale), &original); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to read original scale of knative service %s/%s.", workload.GetNamespace(), workload.GetName()))
+			continue
+		}
+		knativeService := workload.(*unstructured.Unstructured)
+		annotations := getTemplateAnnotations(knativeService)
+		restoreAnnotation(annotations, knativeMinScaleAnnotation, original.MinScale)
+		restoreAnnotation(annotations, knativeMaxScaleAnnotation, original.MaxScale)
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(knativeService.Object, "spec", "template", "metadata", "annotations")
+		} else if err = unstructured.SetNestedStringMap(knativeService.Object, annotations, "spec", "template", "metadata", "annotations"); err != nil {
+			return err
+		}
+		serviceAnnotations := knativeService.GetAnnotations()
+		delete(serviceAnnotations, util.OriginalKnativeScaleAnnotation)
+		knativeService.SetAnnotations(serviceAnnotations)
+		if err = r.Update(ctx, knativeService); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to restore knative service %s/%s.", knativeService.GetNamespace(), knativeService.GetName()))
+		} else {
+			log.Log.Info(fmt.Sprintf("restored knative service NS: %v, Name: %v", knativeService.GetNamespace(), knativeService.GetName()))
+		}
+	}
+	return nil
+}
+
+// knativeTargetScale returns the min-scale and max-scale of the matched workload schedule, max-scale is nil when left as is.
+func knativeTargetScale(desired int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (string, *string) {
+	minScale := desired
+	var maxScale *string
+	if _workloadSchedule.Knative != nil {
+		if _workloadSchedule.Knative.MinScale != nil {
+			minScale = *_workloadSchedule.Knative.MinScale
+		}
+		if _workloadSchedule.Knative.MaxScale != nil {
+			_maxScale := strconv.Itoa(int(*_workloadSchedule.Knative.MaxScale))
+			maxScale = &_maxScale
+		}
+	}
+	return strconv.Itoa(int(minScale)), maxScale
+}
+
+func getTemplateAnnotations(workload client.Object) map[string]string {
+	annotations, _, _ := unstructured.NestedStringMap(workload.(*unstructured.Unstructured).Object, "spec", "template", "metadata", "annotations")
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	return annotations
+}
+
+func setAnnotation(workload client.Object, key string, value string) {
+	annotations := workload.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+	workload.SetAnnotations(annotations)
+}
+
+func restoreAnnotation(annotations map[string]string, key string, value *string) {
+	if value == nil {
+		delete(annotations, key)
+	} else {
+		annotations[key] = *value
+	}
+}
@@ -0,0 +1,67 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func newTestKnativeService(annotations map[string]string, templateAnnotations map[string]interface{}) *unstructured.Unstructured {
+	knativeService := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"metadata": map[string]interface{}{}}}}}
+	if templateAnnotations != nil {
+		_ = unstructured.SetNestedField(knativeService.Object, templateAnnotations, "spec", "template", "metadata", "annotations")
+	}
+	knativeService.SetGroupVersionKind(knativeServiceGVK)
+	knativeService.SetNamespace("dev")
+	knativeService.SetName("hello")
+	knativeService.SetAnnotations(annotations)
+	return knativeService
+}
+
+func TestKnativeServiceHandler(t *testing.T) {
+	adjusted := map[string]string{util.OriginalKnativeScaleAnnotation: `{"maxScale":"5"}`}
+	tests := []struct {
+		name                    string
+		knativeService          *unstructured.Unstructured
+		workloadSchedules       []v1.WorkloadScheduleData
+		wantTemplateAnnotations map[string]string
+		wantAnnotation          bool
+	}{
+		{name: "should set min-scale to desired.", knativeService: newTestKnativeService(nil, map[string]interface{}{knativeMaxScaleAnnotation: "5"}),
+			workloadSchedules:       []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.KNATIVE, Name: "hello", Desired: 2}},
+			wantTemplateAnnotations: map[string]string{knativeMinScaleAnnotation: "2", knativeMaxScaleAnnotation: "5"}, wantAnnotation: true},
+		{name: "should set min-scale and max-scale pair.", knativeService: newTestKnativeService(nil, nil),
+			workloadSchedules:       []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.KNATIVE, Name: "hello", Desired: 2, Knative: &v1.KnativeScale{MinScale: pointer.Int32(1), MaxScale: pointer.Int32(3)}}},
+			wantTemplateAnnotations: map[string]string{knativeMinScaleAnnotation: "1", knativeMaxScaleAnnotation: "3"}, wantAnnotation: true},
+		{name: "should update max-scale when min-scale is in order.", knativeService: newTestKnativeService(adjusted, map[string]interface{}{knativeMinScaleAnnotation: "2", knativeMaxScaleAnnotation: "5"}),
+			workloadSchedules:       []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.KNATIVE, Name: "hello", Desired: 2, Knative: &v1.KnativeScale{MaxScale: pointer.Int32(8)}}},
+			wantTemplateAnnotations: map[string]string{knativeMinScaleAnnotation: "2", knativeMaxScaleAnnotation: "8"}, wantAnnotation: true},
+		{name: "should restore scale annotations when no schedule applies.", knativeService: newTestKnativeService(adjusted, map[string]interface{}{knativeMinScaleAnnotation: "2", knativeMaxScaleAnnotation: "8"}),
+			wantTemplateAnnotations: map[string]string{knativeMaxScaleAnnotation: "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(tt.knativeService)
+			w := New()
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			knativeService := &unstructured.Unstructured{}
+			knativeService.SetGroupVersionKind(knativeServiceGVK)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(tt.knativeService), knativeService); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := getTemplateAnnotations(knativeService); !reflect.DeepEqual(got, tt.wantTemplateAnnotations) {
+				t.Errorf("executeAction() template annotations = %v, want %v", got, tt.wantTemplateAnnotations)
+			}
+			if _, got := knativeService.GetAnnotations()[util.OriginalKnativeScaleAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
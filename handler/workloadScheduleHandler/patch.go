@@ -0,0 +1,268 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// appliedPatch is the patch applied to an object by a workload schedule and the merge patch reverting it.
+type appliedPatch struct {
+	WorkloadSchedule string `json:"workloadSchedule"`
+	Type             string `json:"type"`
+	Patch            string `json:"patch"`
+	// Applied is the merge patch of the fields written by the patch, they are only reverted while they still hold these values.
+	Applied string `json:"applied,omitempty"`
+	Revert  string `json:"revert"`
+}
+
+func validatePatch(patch *workloadschedulerv1.SchedulePatch) error {
+	if patch == nil {
+		return fmt.Errorf("patch needs to be defined for the Patch action")
+	}
+	_patch, err := yaml.YAMLToJSON([]byte(patch.Patch))
+	if err != nil {
+		return err
+	}
+	if patch.Type == workloadschedulerv1.PatchTypeJSON {
+		_, err = jsonpatch.DecodePatch(_patch)
+		return err
+	}
+	var mergePatch map[string]interface{}
+	if err = json.Unmarshal(_patch, &mergePatch); err != nil {
+		return fmt.Errorf("merge patch needs to be an object. %v", err)
+	}
+	return nil
+}
+
+// trackPatchKinds records the kinds patched by _workloadSchedule so patched objects of these kinds are reverted once
+// no schedule with the Patch action applies to them.
+func (w *WorkloadScheduleHandler) trackPatchKinds(_workloadSchedule workloadschedulerv1.WorkloadSchedule) {
+	for _, unit := range _workloadSchedule.Spec.Schedules {
+		if unit.Action != workloadschedulerv1.ActionPatch {
+			continue
+		}
+		for _, kind := range _workloadSchedule.Spec.Selector.Kinds {
+			w.trackKind(patchKindsKey, kind)
+		}
+		return
+	}
+}
+
+// patchWorkload applies the patch of the workload schedule to the workload, the merge patch reverting it is recorded on
+// the workload so it is reverted once no schedule with the Patch action applies to it.
+func (w *WorkloadScheduleHandler) patchWorkload(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, workload client.Object) {
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s/patch", workload.GetNamespace(), kind, workload.GetName())
+	if _, ok := pass.processedWorkloads[processedWorkloadKey]; ok {
+		return
+	}
+	pass.processedWorkloads[processedWorkloadKey] = processedWorkloadKey
+	w.recordKind(r, ctx, patchKindsKey, _workloadSchedule.Kind)
+
+	if !isPatchApplied(workload, _workloadSchedule) && w.isDeferred(_workloadSchedule, pass, workload, fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName()), "patch") {
+		return
+	}
+	if err := applyPatch(ctx, &changeCountingClient{Client: r, pass: pass, kind: kind}, workload, _workloadSchedule); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to patch %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+		w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s/%s/%s: %v", workload.GetNamespace(), kind, workload.GetName(), err))
+	}
+}
+
+func applyPatch(ctx context.Context, r client.Client, workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) error {
+	if _workloadSchedule.Patch == nil {
+		return fmt.Errorf("patch needs to be defined for the Patch action")
+	}
+	current, err := json.Marshal(workload)
+	if err != nil {
+		return err
+	}
+	applied, recorded, err := getAppliedPatch(workload)
+	if err != nil {
+		return err
+	}
+	patchType := getPatchType(_workloadSchedule.Patch)
+	if recorded && isPatchRecorded(applied, _workloadSchedule) {
+		return nil
+	}
+
+	// the original is the object without the patch of another schedule applied and without its record.
+	original := current
+	if recorded {
+		if err = checkPatchHolds(current, applied); err != nil {
+			return err
+		}
+		if original, err = jsonpatch.MergePatch(current, []byte(applied.Revert)); err != nil {
+			return err
+		}
+	}
+	if original, err = withoutPatchAnnotation(original); err != nil {
+		return err
+	}
+
+	_patch, err := yaml.YAMLToJSON([]byte(_workloadSchedule.Patch.Patch))
+	if err != nil {
+		return err
+	}
+	var patched []byte
+	if patchType == string(workloadschedulerv1.PatchTypeJSON) {
+		decoded, err := jsonpatch.DecodePatch(_patch)
+		if err != nil {
+			return err
+		}
+		patched, err = decoded.Apply(original)
+		if err != nil {
+			return err
+		}
+	} else if patched, err = jsonpatch.MergePatch(original, _patch); err != nil {
+		return err
+	}
+
+	revert, err := jsonpatch.CreateMergePatch(patched, original)
+	if err != nil {
+		return err
+	}
+	forward, err := jsonpatch.CreateMergePatch(original, patched)
+	if err != nil {
+		return err
+	}
+	record, err := json.Marshal(appliedPatch{WorkloadSchedule: _workloadSchedule.WorkloadScheduler, Type: patchType, Patch: _workloadSchedule.Patch.Patch, Applied: string(forward), Revert: string(revert)})
+	if err != nil {
+		return err
+	}
+	if patched, err = jsonpatch.MergePatch(patched, []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, util.PatchAnnotation, record))); err != nil {
+		return err
+	}
+	if err = rawMergePatch(ctx, r, workload, current, patched); err != nil {
+		return err
+	}
+	log.Log.Info(fmt.Sprintf("%v patched NS: %v, Name: %v", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+	return nil
+}
+
+// isPatchApplied returns true when the patch of the workload schedule is the one recorded on the workload.
+func isPatchApplied(workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) bool {
+	if _workloadSchedule.Patch == nil {
+		return false
+	}
+	applied, recorded, err := getAppliedPatch(workload)
+	return err == nil && recorded && isPatchRecorded(applied, _workloadSchedule)
+}
+
+func isPatchRecorded(applied appliedPatch, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) bool {
+	return applied.WorkloadSchedule == _workloadSchedule.WorkloadScheduler && applied.Type == getPatchType(_workloadSchedule.Patch) && applied.Patch == _workloadSchedule.Patch.Patch
+}
+
+func getPatchType(patch *workloadschedulerv1.SchedulePatch) string {
+	if len(patch.Type) == 0 {
+		return string(workloadschedulerv1.PatchTypeMerge)
+	}
+	return string(patch.Type)
+}
+
+// revertPatches reverts the patches of objects of the patched kinds no schedule with the Patch action applied to in this run.
+func (w *WorkloadScheduleHandler) revertPatches(r client.Client, ctx context.Context, pass *actionPass) {
+	for _, kind := range w.getTrackedKinds(patchKindsKey) {
+		handler, handlerKind, err := w.getWorkloadHandler(r, workloadschedulerv1.WorkloadScheduleData{Kind: kind, Namespace: util.ALL})
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to revert patches of %s.", kind))
+			continue
+		}
+		workloads, err := handler.ListWorkloads(ctx, r)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("error occurred when fetching %s", kind))
+			continue
+		}
+		for _, workload := range workloads {
+			if _, ok := workload.GetAnnotations()[util.PatchAnnotation]; !ok {
+				continue
+			}
+			if !pass.isRestorable(fmt.Sprintf("%s/%s/%s/patch", workload.GetNamespace(), handlerKind, workload.GetName()), handlerKind) {
+				continue
+			}
+			if _, ok := w.Config.GetIgnoredNamespacesMap()[workload.GetNamespace()]; ok {
+				continue
+			}
+			processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), handlerKind, workload.GetName())
+			if w.isRestoreDeferred(pass, workload, processedWorkloadKey) {
+				continue
+			}
+			if err = revertPatch(ctx, r, workload); err != nil {
+				log.Log.Error(err, fmt.Sprintf("failed to revert patch of %s. Namespace: %s, Name: %s", handlerKind, workload.GetNamespace(), workload.GetName()))
+				w.recordEvent(workload, "PatchNotReverted", err.Error())
+			} else {
+				log.Log.Info(fmt.Sprintf("reverted patch NS: %v, Name: %v", workload.GetNamespace(), workload.GetName()))
+				pass.countChange(workload, processedWorkloadKey)
+			}
+		}
+	}
+}
+
+func revertPatch(ctx context.Context, r client.Client, workload client.Object) error {
+	applied, _, err := getAppliedPatch(workload)
+	if err != nil {
+		return err
+	}
+	current, err := json.Marshal(workload)
+	if err != nil {
+		return err
+	}
+	if err = checkPatchHolds(current, applied); err != nil {
+		return err
+	}
+	reverted, err := jsonpatch.MergePatch(current, []byte(applied.Revert))
+	if err != nil {
+		return err
+	}
+	if reverted, err = withoutPatchAnnotation(reverted); err != nil {
+		return err
+	}
+	return rawMergePatch(ctx, r, workload, current, reverted)
+}
+
+// checkPatchHolds returns a conflict when the fields written by the applied patch no longer hold the values it wrote,
+// records written before the written fields were recorded are not checked.
+func checkPatchHolds(current []byte, applied appliedPatch) error {
+	if len(applied.Applied) == 0 {
+		return nil
+	}
+	reapplied, err := jsonpatch.MergePatch(current, []byte(applied.Applied))
+	if err != nil {
+		return err
+	}
+	if !jsonpatch.Equal(current, reapplied) {
+		return fmt.Errorf("conflict, fields patched by workloadschedule %s were changed since", applied.WorkloadSchedule)
+	}
+	return nil
+}
+
+// rawMergePatch writes the difference between current and desired json of the workload as a merge patch.
+func rawMergePatch(ctx context.Context, r client.Client, workload client.Object, current []byte, desired []byte) error {
+	patch, err := jsonpatch.CreateMergePatch(current, desired)
+	if err != nil {
+		return err
+	}
+	return r.Patch(ctx, workload, client.RawPatch(types.MergePatchType, patch))
+}
+
+func withoutPatchAnnotation(object []byte) ([]byte, error) {
+	return jsonpatch.MergePatch(object, []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, util.PatchAnnotation)))
+}
+
+func getAppliedPatch(workload client.Object) (appliedPatch, bool, error) {
+	var applied appliedPatch
+	record, ok := workload.GetAnnotations()[util.PatchAnnotation]
+	if !ok {
+		return applied, false, nil
+	}
+	if err := json.Unmarshal([]byte(record), &applied); err != nil {
+		return applied, false, fmt.Errorf("invalid %s annotation. %v", util.PatchAnnotation, err)
+	}
+	return applied, true, nil
+}
@@ -0,0 +1,131 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestWorkloadScheduleHandler_patchWorkload(t *testing.T) {
+	mergePatch := &v1.SchedulePatch{Patch: "metadata:\n  labels:\n    tier: night\nspec:\n  paused: true\n"}
+	jsonPatch := &v1.SchedulePatch{Type: v1.PatchTypeJSON, Patch: `[{"op": "add", "path": "/metadata/labels/tier", "value": "batch"}]`}
+	tests := []struct {
+		name              string
+		workloadSchedules [][]v1.WorkloadScheduleData
+		wantTier          string
+		wantPaused        bool
+		wantReplicas      int32
+	}{
+		{name: "should apply merge patch without changing replicas.", workloadSchedules: [][]v1.WorkloadScheduleData{{
+			{WorkloadScheduler: "scale", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 1},
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: mergePatch},
+		}}, wantTier: "night", wantPaused: true, wantReplicas: 1},
+		{name: "should apply json patch.", workloadSchedules: [][]v1.WorkloadScheduleData{{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: jsonPatch},
+		}}, wantTier: "batch", wantReplicas: 2},
+		{name: "should keep patch applied on following runs.", workloadSchedules: [][]v1.WorkloadScheduleData{
+			{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: mergePatch}},
+			{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: mergePatch}},
+		}, wantTier: "night", wantPaused: true, wantReplicas: 2},
+		{name: "should revert previous patch before applying another one.", workloadSchedules: [][]v1.WorkloadScheduleData{
+			{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: mergePatch}},
+			{{WorkloadScheduler: "other", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: jsonPatch}},
+		}, wantTier: "batch", wantReplicas: 2},
+		{name: "should revert patch when no schedule applies.", workloadSchedules: [][]v1.WorkloadScheduleData{
+			{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: mergePatch}},
+			{},
+		}, wantTier: "web", wantReplicas: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := newTestDeployment("dev", "api", 2, map[string]string{"tier": "web"})
+			c := newTestClient(deployment)
+			w := New()
+			for _, workloadSchedules := range tt.workloadSchedules {
+				if _, err := w.executeAction(workloadSchedules, c, context.Background()); err != nil {
+					t.Fatalf("executeAction() error = %v", err)
+				}
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := deployment.Labels["tier"]; got != tt.wantTier {
+				t.Errorf("executeAction() tier = %v, want %v", got, tt.wantTier)
+			}
+			if deployment.Spec.Paused != tt.wantPaused {
+				t.Errorf("executeAction() paused = %v, want %v", deployment.Spec.Paused, tt.wantPaused)
+			}
+			if *deployment.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, tt.wantReplicas)
+			}
+			if _, got := deployment.Annotations[util.PatchAnnotation]; got != (tt.wantTier != "web") {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantTier != "web")
+			}
+		})
+	}
+}
+
+func Test_validatePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   *v1.SchedulePatch
+		wantErr bool
+	}{
+		{name: "should accept yaml merge patch.", patch: &v1.SchedulePatch{Patch: "spec:\n  paused: true\n"}},
+		{name: "should accept json patch.", patch: &v1.SchedulePatch{Type: v1.PatchTypeJSON, Patch: `[{"op": "remove", "path": "/spec/paused"}]`}},
+		{name: "should reject missing patch.", wantErr: true},
+		{name: "should reject merge patch that is not an object.", patch: &v1.SchedulePatch{Patch: "- paused"}, wantErr: true},
+		{name: "should reject json patch that is not a list of operations.", patch: &v1.SchedulePatch{Type: v1.PatchTypeJSON, Patch: `{"op": "remove"}`}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePatch(tt.patch); (err != nil) != tt.wantErr {
+				t.Errorf("validatePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_revertPatches_conflict(t *testing.T) {
+	mergePatch := &v1.SchedulePatch{Patch: "metadata:\n  labels:\n    tier: night\n"}
+	tests := []struct {
+		name           string
+		tier           string
+		wantTier       string
+		wantAnnotation bool
+	}{
+		{name: "should revert patch whose fields hold the patched values.", tier: "night", wantTier: "web"},
+		{name: "should not revert patch whose fields were changed since.", tier: "manual", wantTier: "manual", wantAnnotation: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := newTestDeployment("dev", "api", 2, map[string]string{"tier": "web"})
+			c := newTestClient(deployment)
+			w := New()
+			if _, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionPatch, Patch: mergePatch}}, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			deployment.Labels["tier"] = tt.tier
+			if err := c.Update(context.Background(), deployment); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if _, err := w.executeAction(nil, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := deployment.Labels["tier"]; got != tt.wantTier {
+				t.Errorf("executeAction() tier = %v, want %v", got, tt.wantTier)
+			}
+			if _, got := deployment.Annotations[util.PatchAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
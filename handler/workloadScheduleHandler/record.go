@@ -0,0 +1,107 @@
+package workloadScheduleHandler
+
+import (
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strings"
+)
+
+// kindsRecord is the ConfigMap in the namespace of the operator recording the kinds of the objects changed by workload
+// schedules, so changed objects are restored after a restart even when the workload schedule changing them is gone.
+const kindsRecord = "workload-scheduler-kinds"
+
+// The keys of kindsRecord, each holds the comma separated kinds changed one way. Objects are only restored for the
+// kinds recorded under the key of the change.
+const (
+	patchKindsKey                   = "patches"
+	horizontalPodAutoscalerKindsKey = "horizontalpodautoscalers"
+	scaledObjectKindsKey            = "scaledobjects"
+	resourceKindsKey                = "resources"
+	baselineKindsKey                = "baselines"
+)
+
+// trackKind tracks kind under key, it returns false when kind is already tracked.
+func (w *WorkloadScheduleHandler) trackKind(key string, kind string) bool {
+	if len(kind) == 0 {
+		return false
+	}
+	if w.trackedKinds == nil {
+		w.trackedKinds = make(map[string]map[string]struct{})
+	}
+	kinds, ok := w.trackedKinds[key]
+	if !ok {
+		kinds = make(map[string]struct{})
+		w.trackedKinds[key] = kinds
+	}
+	if _, ok = kinds[kind]; ok {
+		return false
+	}
+	kinds[kind] = struct{}{}
+	return true
+}
+
+// getTrackedKinds returns the sorted kinds tracked under key.
+func (w *WorkloadScheduleHandler) getTrackedKinds(key string) []string {
+	var kinds []string
+	for kind := range w.trackedKinds[key] {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// recordKind tracks kind under key and records it when it was not tracked yet, it is called before an object of kind
+// is changed so the change is restored even when the operator restarts right after.
+func (w *WorkloadScheduleHandler) recordKind(r client.Client, ctx context.Context, key string, kind string) {
+	if !w.trackKind(key, kind) {
+		return
+	}
+	if err := w.syncKinds(r, ctx); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to record %s of %s.", key, kind))
+	}
+}
+
+// syncKinds tracks the kinds recorded by earlier runs and records the kinds tracked since, the record is read from the
+// API server as the operator does not watch configmaps. Kinds are not recorded without OPERATOR_NAMESPACE.
+func (w *WorkloadScheduleHandler) syncKinds(r client.Client, ctx context.Context) error {
+	namespace, ok := w.Config.Provider.LookUpEnv(config.OperatorNamespace)
+	if !ok || len(namespace) == 0 {
+		return nil
+	}
+	var reader client.Reader = r
+	if w.APIReader != nil {
+		reader = w.APIReader
+	}
+	record := &core.ConfigMap{}
+	err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: kindsRecord}, record)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	recorded := err == nil
+	for key, kinds := range record.Data {
+		for _, kind := range strings.Split(kinds, ",") {
+			w.trackKind(key, kind)
+		}
+	}
+	data := make(map[string]string)
+	for key := range w.trackedKinds {
+		data[key] = strings.Join(w.getTrackedKinds(key), ",")
+	}
+	if len(data) == 0 || reflect.DeepEqual(record.Data, data) {
+		return nil
+	}
+	record.Data = data
+	if !recorded {
+		record.Namespace = namespace
+		record.Name = kindsRecord
+		return r.Create(ctx, record)
+	}
+	return r.Update(ctx, record)
+}
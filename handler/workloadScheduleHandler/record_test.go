@@ -0,0 +1,37 @@
+package workloadScheduleHandler
+
+import (
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestWorkloadScheduleHandler_syncKinds(t *testing.T) {
+	t.Setenv(config.OperatorNamespace, "operator")
+	c := newTestClient()
+	w := New()
+	w.trackKind(patchKindsKey, "ConfigMap.v1")
+	if err := w.syncKinds(c, context.Background()); err != nil {
+		t.Fatalf("syncKinds() error = %v", err)
+	}
+
+	// a restarted handler tracks the kinds recorded before the restart.
+	restarted := New()
+	restarted.APIReader = c
+	restarted.recordKind(c, context.Background(), patchKindsKey, "Secret.v1")
+	restarted.recordKind(c, context.Background(), horizontalPodAutoscalerKindsKey, "deployment")
+	if got := restarted.getTrackedKinds(patchKindsKey); len(got) != 2 || got[0] != "ConfigMap.v1" {
+		t.Errorf("syncKinds() tracked = %v, want ConfigMap.v1 and Secret.v1", got)
+	}
+	record := &core.ConfigMap{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "operator", Name: kindsRecord}, record); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	for key, want := range map[string]string{patchKindsKey: "ConfigMap.v1,Secret.v1", horizontalPodAutoscalerKindsKey: "deployment"} {
+		if got := record.Data[key]; got != want {
+			t.Errorf("syncKinds() recorded %s = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"bennsimon.github.io/workload-scheduler-operator/util/expression"
+	"context"
+	"fmt"
+	"github.com/google/cel-go/cel"
+	autoscaling "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"math"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WorkloadHandler manages workloads of a single kind, it is registered for that kind on the WorkloadScheduleHandler.
+//
+// Handlers listing typed objects from the manager's cache by name implement TypedWorkloadHandler so a config.IndexedField
+// index is registered for that type.
+type WorkloadHandler interface {
+	// ListWorkloads returns the candidate workloads matching opts.
+	ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error)
+	// GetCurrent returns the current state of the workload expressed as replicas.
+	GetCurrent(ctx context.Context, r client.Client, workload client.Object) (int32, error)
+	// ComputeDesired returns the state the workload should be in for the matched workload schedule.
+	ComputeDesired(ctx context.Context, r client.Client, workload client.Object, current int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error)
+	// Apply brings the workload to the desired state.
+	Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error
+}
+
+// TypedWorkloadHandler is optionally implemented by a WorkloadHandler listing typed objects from the manager's cache.
+type TypedWorkloadHandler interface {
+	// NewWorkload returns an empty object of the type of the workloads, a config.IndexedField index is registered for it.
+	NewWorkload() client.Object
+}
+
+// StatusReporter is optionally implemented by a WorkloadHandler to report workloads it brought to the desired state
+// in the status of the matched WorkloadSchedule.
+type StatusReporter interface {
+	ReportStatus(workload client.Object, desired int32, status *workloadschedulerv1.WorkloadScheduleStatus)
+}
+
+// WorkloadScheduleApplier is optionally implemented by a WorkloadHandler whose desired state depends on more than desired replicas
+// of the matched WorkloadSchedule, it is used in place of comparing current with desired and WorkloadHandler.Apply.
+type WorkloadScheduleApplier interface {
+	// InOrder reports whether the workload is in the state of the matched workload schedule.
+	InOrder(workload client.Object, desired int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) bool
+	// ApplyWorkloadSchedule brings the workload to the state of the matched workload schedule.
+	ApplyWorkloadSchedule(ctx context.Context, r client.Client, workload client.Object, desired int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) error
+}
+
+// StateToggler is optionally implemented by a WorkloadHandler whose workloads are either running or stopped, expressed
+// as 1 or 0 replicas, replica bounds do not apply to them.
+type StateToggler interface {
+	// TogglesState reports whether the workloads of the handler are only running or stopped.
+	TogglesState() bool
+}
+
+// ScaleDownPreparer is optionally implemented by a WorkloadHandler whose workloads are prepared before they are scaled to 0.
+type ScaleDownPreparer interface {
+	// PrepareScaleDown returns true once the workload is ready to be scaled to 0 by the workload schedule.
+	PrepareScaleDown(ctx context.Context, r client.Client, workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (bool, error)
+	// CancelScaleDown clears the preparation of a scale down to 0 that did not happen.
+	CancelScaleDown(ctx context.Context, r client.Client, workload client.Object) error
+}
+
+// WorkloadRestorer is optionally implemented by a WorkloadHandler reverting workloads no workload schedule applies to anymore.
+type WorkloadRestorer interface {
+	// RestoreWorkloads reverts the workloads changed by a workload schedule, except those isKept returns true for as they are
+	// processed or their restore is deferred in this run.
+	RestoreWorkloads(ctx context.Context, r client.Client, isKept func(workload client.Object) bool) error
+}
+
+// WorkloadHandlerRegistry maps a kind, as used in WorkloadSelector.Kinds, to its handler.
+type WorkloadHandlerRegistry map[string]WorkloadHandler
+
+// NewWorkloadHandlerRegistry returns a registry with the built-in handlers registered.
+func NewWorkloadHandlerRegistry() WorkloadHandlerRegistry {
+	return WorkloadHandlerRegistry{
+		util.DEPLOYMENT:  NewDeploymentHandler(),
+		util.STATEFULSET: NewStatefulSetHandler(),
+		util.CRONJOB:     NewCronJobHandler(),
+		util.JOB:         NewJobHandler(),
+		util.DAEMONSET:   NewDaemonSetHandler(),
+		util.KNATIVE:     NewKnativeServiceHandler(),
+		util.QUOTA:       NewResourceQuotaHandler(),
+		util.SERVICE:     NewServiceHandler(),
+	}
+}
+
+// Kinds returns the registered kinds sorted.
+func (h WorkloadHandlerRegistry) Kinds() []string {
+	var kinds []string
+	for kind := range h {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// IndexedWorkloads returns an empty object of the type of the workloads of each TypedWorkloadHandler registered, sorted by kind.
+func (h WorkloadHandlerRegistry) IndexedWorkloads() []client.Object {
+	var workloads []client.Object
+	for _, kind := range h.Kinds() {
+		if typed, ok := h[kind].(TypedWorkloadHandler); ok {
+			workloads = append(workloads, typed.NewWorkload())
+		}
+	}
+	return workloads
+}
+
+// RegisterWorkloadHandler registers handler for kind, replacing any handler registered for it.
+func (w *WorkloadScheduleHandler) RegisterWorkloadHandler(kind string, handler WorkloadHandler) {
+	if w.WorkloadHandlers == nil {
+		w.WorkloadHandlers = NewWorkloadHandlerRegistry()
+	}
+	w.WorkloadHandlers[kind] = handler
+}
+
+// DesiredComputer computes the desired replicas from the matched workload schedule, handlers embed it to share the default behaviour.
+type DesiredComputer struct{}
+
+func (d DesiredComputer) ComputeDesired(_ context.Context, _ client.Client, workload client.Object, current int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error) {
+	if len(_workloadSchedule.DesiredExpression) == 0 && _workloadSchedule.Percentage == nil {
+		return _workloadSchedule.Desired, nil
+	}
+	baseline, err := getBaseline(workload, current)
+	if err != nil {
+		return 0, err
+	}
+	if len(_workloadSchedule.DesiredExpression) != 0 {
+		_, desired, err := compileExpressions(_workloadSchedule)
+		if err != nil {
+			return 0, err
+		}
+		return expression.EvaluateDesired(desired, workload, current, baseline)
+	}
+	return computePercentage(baseline, *_workloadSchedule.Percentage), nil
+}
+
+// getBaseline returns the recorded baseline replicas of the workload, current when none is recorded.
+func getBaseline(workload client.Object, current int32) (int32, error) {
+	baseline, ok := workload.GetAnnotations()[util.BaselineReplicasAnnotation]
+	if !ok {
+		return current, nil
+	}
+	_baseline, err := strconv.ParseInt(baseline, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %s. %v", util.BaselineReplicasAnnotation, baseline, err)
+	}
+	return int32(_baseline), nil
+}
+
+func computePercentage(baseline int32, percentage workloadschedulerv1.DesiredPercentage) int32 {
+	replicas := float64(baseline) * float64(percentage.Value) / 100
+	switch percentage.Rounding {
+	case workloadschedulerv1.RoundingDown:
+		replicas = math.Floor(replicas)
+	case workloadschedulerv1.RoundingNearest:
+		replicas = math.Round(replicas)
+	default:
+		replicas = math.Ceil(replicas)
+	}
+	if desired := int32(replicas); desired > percentage.Minimum {
+		return desired
+	}
+	return percentage.Minimum
+}
+
+// recordBaseline records the current replicas of the workload as its baseline before the operator first changes it,
+// handlers implementing WorkloadScheduleApplier keep their own record.
+func recordBaseline(ctx context.Context, r client.Client, handler WorkloadHandler, workload client.Object, current int32) error {
+	if _, ok := handler.(WorkloadScheduleApplier); ok {
+		return nil
+	}
+	if _, ok := workload.GetAnnotations()[util.BaselineReplicasAnnotation]; ok {
+		return nil
+	}
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	setAnnotation(workload, util.BaselineReplicasAnnotation, strconv.Itoa(int(current)))
+	return r.Patch(ctx, workload, patch)
+}
+
+// clearBaseline clears the baseline of the workload once it is brought back to it, so the next change records the replicas
+// the owners set since.
+func clearBaseline(ctx context.Context, r client.Client, handler WorkloadHandler, workload client.Object, desired int32) error {
+	if _, ok := handler.(WorkloadScheduleApplier); ok {
+		return nil
+	}
+	if baseline, ok := workload.GetAnnotations()[util.BaselineReplicasAnnotation]; !ok || baseline != strconv.Itoa(int(desired)) {
+		return nil
+	}
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	annotations := workload.GetAnnotations()
+	delete(annotations, util.BaselineReplicasAnnotation)
+	workload.SetAnnotations(annotations)
+	return r.Patch(ctx, workload, patch)
+}
+
+// restoreBaselines clears the baseline of workloads of registered kinds no workload schedule applies to anymore, they are
+// brought back to their baseline replicas with RESTORE_BASELINE. A later change records the replicas the owners set since.
+// Only workloads of the kinds recorded with a baseline are listed.
+func (w *WorkloadScheduleHandler) restoreBaselines(r client.Client, ctx context.Context, pass *actionPass) {
+	restore := w.Config.LookUpBooleanEnv(config.RestoreBaseline)
+	for _, kind := range w.getTrackedKinds(baselineKindsKey) {
+		handler, ok := w.WorkloadHandlers[kind]
+		if !ok {
+			continue
+		}
+		if _, ok = handler.(WorkloadScheduleApplier); ok {
+			continue
+		}
+		workloads, err := handler.ListWorkloads(ctx, r)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("error occurred when fetching %s", kind))
+			continue
+		}
+		for _, workload := range workloads {
+			if _, ok := workload.GetAnnotations()[util.BaselineReplicasAnnotation]; !ok {
+				continue
+			}
+			if !pass.isRestorable(fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName()), kind) {
+				continue
+			}
+			if _, ok := w.Config.GetIgnoredNamespacesMap()[workload.GetNamespace()]; ok {
+				continue
+			}
+			w.restoreBaseline(r, ctx, pass, kind, handler, workload, restore)
+		}
+	}
+}
+
+func (w *WorkloadScheduleHandler) restoreBaseline(r client.Client, ctx context.Context, pass *actionPass, kind string, handler WorkloadHandler, workload client.Object, restore bool) {
+	currentReplicaCount, err := handler.GetCurrent(ctx, r, workload)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to get current state of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+		return
+	}
+	baseline, err := getBaseline(workload, currentReplicaCount)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to restore %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+		return
+	}
+
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	changed := restore && currentReplicaCount != baseline
+	if changed && w.isRestoreDeferred(pass, workload, processedWorkloadKey) {
+		return
+	}
+
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	annotations := workload.GetAnnotations()
+	delete(annotations, util.BaselineReplicasAnnotation)
+	workload.SetAnnotations(annotations)
+	if !changed {
+		err = r.Patch(ctx, workload, patch)
+	} else {
+		log.Log.Info(fmt.Sprintf("restoring NS: %v, Name: %v, from %v to baseline %v", workload.GetNamespace(), workload.GetName(), currentReplicaCount, baseline))
+		err = handler.Apply(ctx, r, workload, baseline)
+	}
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to restore %s from %d to baseline %d. Namespace: %s, Name: %s", kind, currentReplicaCount, baseline, workload.GetNamespace(), workload.GetName()))
+	} else if changed {
+		pass.countChange(workload, processedWorkloadKey)
+	}
+}
+
+// getWorkloadHandler returns the handler for the kind of the workload schedule and the kind used to track processed workloads.
+func (w *WorkloadScheduleHandler) getWorkloadHandler(r client.Client, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (WorkloadHandler, string, error) {
+	kind := _workloadSchedule.Kind
+	if handler, ok := w.WorkloadHandlers[kind]; ok {
+		return handler, kind, nil
+	}
+	if !util.IsKindReference(kind) {
+		return nil, "", fmt.Errorf("kind %s is not registered", kind)
+	}
+
+	mapping, err := ResolveKind(r.RESTMapper(), kind)
+	if err != nil {
+		return nil, "", err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace && _workloadSchedule.Namespace != util.ALL {
+		return nil, "", fmt.Errorf("%s is cluster scoped while namespace %s is selected", kind, _workloadSchedule.Namespace)
+	}
+	return NewScaleHandler(mapping), strings.ToLower(mapping.GroupVersionKind.GroupKind().String()), nil
+}
+
+// actionPass holds the state of a single executeAction run.
+type actionPass struct {
+	processedWorkloads map[string]string
+	statuses           map[string]*workloadschedulerv1.WorkloadScheduleStatus
+	// unlistedKinds are the kinds whose workloads failed to be listed in this run, they are not restored as their workloads
+	// may be scheduled.
+	unlistedKinds map[string]struct{}
+	// scheduledReplicas holds the desired replicas computed in this run by processed workload key.
+	scheduledReplicas map[string]int32
+	// followers are the workloads following another workload, adjusted once the other workloads are.
+	followers []follower
+	// dependents are the workloads whose dependencies were not evaluated yet when they were, evaluated again at the end of the run.
+	dependents []follower
+	// resolvingDependents is set while the dependents are evaluated again.
+	resolvingDependents bool
+	// waitingWorkloads are the workloads whose change waits for dependencies in this run.
+	waitingWorkloads map[string]struct{}
+	// horizontalPodAutoscalers are the HorizontalPodAutoscalers listed in this run by namespace and scale target.
+	horizontalPodAutoscalers map[string]map[string]*autoscaling.HorizontalPodAutoscaler
+	// scaledObjects are the ScaledObjects listed in this run by namespace and scale target.
+	scaledObjects map[string]map[string]*unstructured.Unstructured
+	// changedWorkloads are the workloads changed in this run, changes counts them and namespaceChanges counts them by namespace.
+	changedWorkloads map[string]struct{}
+	changes          int
+	namespaceChanges map[string]int
+}
+
+type follower struct {
+	workloadSchedule workloadschedulerv1.WorkloadScheduleData
+	kind             string
+	handler          WorkloadHandler
+	workload         client.Object
+}
+
+// isRestorable reports whether the workload of kind is restored, it is not when it was processed in this run or the workloads
+// of its kind failed to be listed.
+func (p *actionPass) isRestorable(processedWorkloadKey string, kind string) bool {
+	if _, ok := p.unlistedKinds[kind]; ok {
+		return false
+	}
+	_, ok := p.processedWorkloads[processedWorkloadKey]
+	return !ok
+}
+
+func newActionPass() *actionPass {
+	return &actionPass{processedWorkloads: make(map[string]string), unlistedKinds: make(map[string]struct{}), horizontalPodAutoscalers: make(map[string]map[string]*autoscaling.HorizontalPodAutoscaler), scaledObjects: make(map[string]map[string]*unstructured.Unstructured), statuses: make(map[string]*workloadschedulerv1.WorkloadScheduleStatus), scheduledReplicas: make(map[string]int32), changedWorkloads: make(map[string]struct{}), namespaceChanges: make(map[string]int), waitingWorkloads: make(map[string]struct{})}
+}
+
+func (w *WorkloadScheduleHandler) executeActionOnKind(r client.Client, ctx context.Context, opts []client.ListOption, _workloadSchedule workloadschedulerv1.WorkloadScheduleData, pass *actionPass, filter cel.Program, kind string, handler WorkloadHandler) {
+	workloads, err := handler.ListWorkloads(ctx, r, opts...)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("error occurred when fetching %s", kind))
+		pass.unlistedKinds[kind] = struct{}{}
+	} else {
+		if len(workloads) == 0 && w.Config.LookUpBooleanEnv(config.Debug) {
+			log.Log.Info(fmt.Sprintf("%s: %s not found. NS: %s, WS: %s", kind, _workloadSchedule.Name, _workloadSchedule.Namespace, _workloadSchedule.WorkloadScheduler))
+		}
+		for _, workload := range workloads {
+			if !w.matchesFilter(filter, workload, _workloadSchedule) {
+				continue
+			}
+			w.AdjustReplicas(_workloadSchedule, r, ctx, pass, kind, handler, workload)
+		}
+	}
+}
+
+func (w *WorkloadScheduleHandler) AdjustReplicas(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, handler WorkloadHandler, workload client.Object) {
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	if _, ok := w.Config.GetIgnoredNamespacesMap()[workload.GetNamespace()]; ok {
+		if w.Config.LookUpBooleanEnv(config.Debug) {
+			log.Log.Info(fmt.Sprintf("ignored workload in %s namespace.", workload.GetNamespace()))
+		}
+		return
+	}
+	switch _workloadSchedule.Action {
+	case workloadschedulerv1.ActionRestart:
+		w.restartWorkload(_workloadSchedule, r, ctx, pass, kind, workload)
+		return
+	case workloadschedulerv1.ActionPatch:
+		w.patchWorkload(_workloadSchedule, r, ctx, pass, kind, workload)
+		return
+	}
+	if w.Config.LookUpBooleanEnv(config.Debug) {
+		log.Log.Info(fmt.Sprintf("fetched: %s .... %v", processedWorkloadKey, pass.processedWorkloads))
+	}
+	if _, ok := pass.processedWorkloads[processedWorkloadKey]; ok {
+		if w.Config.LookUpBooleanEnv(config.Debug) {
+			log.Log.Info(fmt.Sprintf("skipped in loop: NS: %s, Kind: %s, Name: %s.", _workloadSchedule.Namespace, _workloadSchedule.Kind, _workloadSchedule.Name))
+		}
+		return
+	}
+
+	// the workload is claimed now so lower ranked workload schedules skip it, it is adjusted once the workload it follows is.
+	if _workloadSchedule.Follow != nil {
+		pass.followers = append(pass.followers, follower{workloadSchedule: _workloadSchedule, kind: kind, handler: handler, workload: workload})
+		pass.processedWorkloads[processedWorkloadKey] = processedWorkloadKey
+		return
+	}
+	w.adjustWorkload(_workloadSchedule, r, ctx, pass, kind, handler, workload)
+}
+
+func (w *WorkloadScheduleHandler) adjustWorkload(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, handler WorkloadHandler, workload client.Object) {
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	// the workload is processed even when it fails to be adjusted, so it is not restored while it is scheduled.
+	defer func() {
+		pass.processedWorkloads[processedWorkloadKey] = processedWorkloadKey
+	}()
+	// the resources of the workload are kept while a schedule resizes it, even when its change waits or fails.
+	if _workloadSchedule.Resources != nil {
+		resourcesKey := fmt.Sprintf("%s/resources", processedWorkloadKey)
+		pass.processedWorkloads[resourcesKey] = resourcesKey
+	}
+	currentReplicaCount, err := handler.GetCurrent(ctx, r, workload)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to get current state of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+		return
+	}
+	desired, err := handler.ComputeDesired(ctx, r, workload, currentReplicaCount, _workloadSchedule)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to compute desired state of %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+		w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s: %v", processedWorkloadKey, err))
+		return
+	}
+	clamped := ""
+	if scalesByReplicas(handler) {
+		if desired, clamped, err = w.clampReplicas(workload, _workloadSchedule, desired); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to get replica bounds of %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+			w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s: %v", processedWorkloadKey, err))
+			return
+		}
+	}
+	if len(clamped) != 0 {
+		log.Log.Info(fmt.Sprintf("%v %s. Namespace: %s, Name: %s", _workloadSchedule.WorkloadScheduler, clamped, workload.GetNamespace(), workload.GetName()))
+		w.getStatus(_workloadSchedule, pass.statuses).ClampedWorkloads = append(w.getStatus(_workloadSchedule, pass.statuses).ClampedWorkloads, fmt.Sprintf("%s: %s", processedWorkloadKey, clamped))
+	}
+	pass.scheduledReplicas[processedWorkloadKey] = desired
+
+	change := &workloadChange{workloadSchedule: _workloadSchedule, kind: kind, handler: handler, workload: workload, current: currentReplicaCount, desired: desired}
+	for _, hook := range preApplyHooks {
+		if hook(w, r, ctx, pass, change) {
+			return
+		}
+	}
+	desired = change.desired
+
+	inOrder := currentReplicaCount == desired
+	apply := handler.Apply
+	if applier, ok := handler.(WorkloadScheduleApplier); ok {
+		inOrder = applier.InOrder(workload, desired, _workloadSchedule)
+		apply = func(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+			return applier.ApplyWorkloadSchedule(ctx, r, workload, desired, _workloadSchedule)
+		}
+	}
+
+	if !inOrder {
+		if w.isDeferred(_workloadSchedule, pass, workload, processedWorkloadKey, fmt.Sprintf("update from %v to %v", currentReplicaCount, desired)) {
+			return
+		}
+		for _, hook := range preWriteHooks {
+			if hook(w, r, ctx, pass, change) {
+				return
+			}
+		}
+		if _, ok := handler.(WorkloadScheduleApplier); !ok {
+			w.recordKind(r, ctx, baselineKindsKey, kind)
+		}
+		if err = recordBaseline(ctx, r, handler, workload, currentReplicaCount); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to record baseline of %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+			return
+		}
+		log.Log.Info(fmt.Sprintf("%v updating NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), currentReplicaCount, desired))
+		err = apply(ctx, r, workload, desired)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to update %s from %d to %d for workloadschedule %s.", kind, currentReplicaCount, desired, _workloadSchedule.WorkloadScheduler))
+		} else {
+			log.Log.Info(fmt.Sprintf("%v updated NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), currentReplicaCount, desired))
+			pass.countChange(workload, processedWorkloadKey)
+			for _, hook := range appliedHooks {
+				hook(w, r, ctx, pass, change)
+			}
+			w.reportStatus(_workloadSchedule, pass.statuses, handler, workload, desired)
+		}
+	} else {
+		log.Log.Info(fmt.Sprintf("got %s %s in order with %s. Namespace: %s, Name: %s, Desired: %d", workload.GetName(), kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), desired))
+		w.reportStatus(_workloadSchedule, pass.statuses, handler, workload, desired)
+	}
+}
+
+func (w *WorkloadScheduleHandler) reportStatus(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, statuses map[string]*workloadschedulerv1.WorkloadScheduleStatus, handler WorkloadHandler, workload client.Object, desired int32) {
+	reporter, ok := handler.(StatusReporter)
+	if !ok {
+		return
+	}
+	reporter.ReportStatus(workload, desired, w.getStatus(_workloadSchedule, statuses))
+}
+
+// getStatus returns the status of the workload schedule observed in this run.
+func (w *WorkloadScheduleHandler) getStatus(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, statuses map[string]*workloadschedulerv1.WorkloadScheduleStatus) *workloadschedulerv1.WorkloadScheduleStatus {
+	if _, ok := statuses[_workloadSchedule.WorkloadScheduler]; !ok {
+		statuses[_workloadSchedule.WorkloadScheduler] = &workloadschedulerv1.WorkloadScheduleStatus{}
+	}
+	return statuses[_workloadSchedule.WorkloadScheduler]
+}
+
+// restoreWorkloads lets every WorkloadRestorer revert the workloads not processed in this run, within the limits of changes per run.
+func (w *WorkloadScheduleHandler) restoreWorkloads(r client.Client, ctx context.Context, pass *actionPass) {
+	for _, kind := range w.WorkloadHandlers.Kinds() {
+		restorer, ok := w.WorkloadHandlers[kind].(WorkloadRestorer)
+		if !ok {
+			continue
+		}
+		if _, ok = pass.unlistedKinds[kind]; ok {
+			continue
+		}
+		isKept := func(workload client.Object) bool {
+			processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+			if _, processed := pass.processedWorkloads[processedWorkloadKey]; processed {
+				return true
+			}
+			return w.isRestoreDeferred(pass, workload, processedWorkloadKey)
+		}
+		if err := restorer.RestoreWorkloads(ctx, &changeCountingClient{Client: r, pass: pass, kind: kind}, isKept); err != nil {
+			log.Log.Error(err, fmt.Sprintf("error occurred when restoring %s", kind))
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// configMapHandler is a handler registered from outside the built-in kinds, it keeps replicas in a ConfigMap.
+type configMapHandler struct {
+	DesiredComputer
+}
+
+func (c *configMapHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var configMaps core.ConfigMapList
+	if err := r.List(ctx, &configMaps, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range configMaps.Items {
+		workloads = append(workloads, &configMaps.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (c *configMapHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	replicas, err := strconv.Atoi(workload.(*core.ConfigMap).Data["replicas"])
+	return int32(replicas), err
+}
+
+func (c *configMapHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	configMap := workload.(*core.ConfigMap)
+	configMap.Data["replicas"] = strconv.Itoa(int(desired))
+	return r.Update(ctx, configMap)
+}
+
+func (c *configMapHandler) NewWorkload() client.Object {
+	return &core.ConfigMap{}
+}
+
+func TestWorkloadHandlerRegistry_IndexedWorkloads(t *testing.T) {
+	registry := NewWorkloadHandlerRegistry()
+	registry["configmap"] = &configMapHandler{}
+	var got []string
+	for _, workload := range registry.IndexedWorkloads() {
+		got = append(got, reflect.TypeOf(workload).Elem().Name())
+	}
+	if want := []string{"ConfigMap", "CronJob", "DaemonSet", "Deployment", "Job", "ResourceQuota", "Service", "StatefulSet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IndexedWorkloads() got = %v, want %v", got, want)
+	}
+}
+
+func TestWorkloadHandlerRegistry_Kinds(t *testing.T) {
+	registry := NewWorkloadHandlerRegistry()
+	registry["configmap"] = &configMapHandler{}
+	if got, want := registry.Kinds(), []string{"configmap", "cronjob", "daemonset", "deployment", "job", "knativeservice", "resourcequota", "service", "statefulset"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Kinds() got = %v, want %v", got, want)
+	}
+}
+
+func TestWorkloadScheduleHandler_RegisterWorkloadHandler(t *testing.T) {
+	c := newTestClient(&core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-a"}, Data: map[string]string{"replicas": "3"}})
+	w := New()
+	w.RegisterWorkloadHandler("configmap", &configMapHandler{})
+
+	workloadSchedule := &v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Schedule: "weekday"}}, Selector: v1.WorkloadSelector{Kinds: []string{"configmap"}}}}
+	w.ScheduleHandler = &NopScheduleHandler{}
+	if err := w.ValidateWorkloadSchedule(workloadSchedule, c); err != nil {
+		t.Fatalf("ValidateWorkloadSchedule() error = %v", err)
+	}
+
+	_, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "default", Kind: "configmap", Name: "*", Desired: 1}}, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	configMap := &core.ConfigMap{}
+	if err = c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "app-a"}, configMap); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := configMap.Data["replicas"]; got != "1" {
+		t.Errorf("executeAction() replicas = %v, want 1", got)
+	}
+}
+
+func TestWorkloadScheduleHandler_getWorkloadHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     v1.WorkloadScheduleData
+		wantKind string
+		wantErr  bool
+	}{
+		{name: "should return registered handler.", data: v1.WorkloadScheduleData{Kind: "deployment", Namespace: "*"}, wantKind: "deployment"},
+		{name: "should return scale handler for kind references.", data: v1.WorkloadScheduleData{Kind: "ReplicaSet.v1.apps", Namespace: "*"}, wantKind: "replicaset.apps"},
+		{name: "should return error for unregistered kinds.", data: v1.WorkloadScheduleData{Kind: "pod", Namespace: "*"}, wantErr: true},
+		{name: "should return error for unknown kind references.", data: v1.WorkloadScheduleData{Kind: "Rollout.argoproj.io", Namespace: "*"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New()
+			_, kind, err := w.getWorkloadHandler(newTestClient(), tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getWorkloadHandler() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if kind != tt.wantKind {
+				t.Errorf("getWorkloadHandler() kind = %v, want %v", kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestDesiredComputer_ComputeDesired(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		current     int32
+		data        v1.WorkloadScheduleData
+		want        int32
+		wantErr     bool
+	}{
+		{name: "should return desired.", current: 4, data: v1.WorkloadScheduleData{Desired: 2}, want: 2},
+		{name: "should round percentage of current up by default.", current: 4, data: v1.WorkloadScheduleData{Percentage: &v1.DesiredPercentage{Value: 30}}, want: 2},
+		{name: "should round percentage down.", current: 4, data: v1.WorkloadScheduleData{Percentage: &v1.DesiredPercentage{Value: 30, Rounding: v1.RoundingDown}}, want: 1},
+		{name: "should round percentage to nearest.", current: 5, data: v1.WorkloadScheduleData{Percentage: &v1.DesiredPercentage{Value: 30, Rounding: v1.RoundingNearest}}, want: 2},
+		{name: "should not go below minimum.", current: 4, data: v1.WorkloadScheduleData{Percentage: &v1.DesiredPercentage{Value: 10, Rounding: v1.RoundingDown, Minimum: 1}}, want: 1},
+		{name: "should compute percentage of recorded baseline.", annotations: map[string]string{util.BaselineReplicasAnnotation: "10"}, current: 3,
+			data: v1.WorkloadScheduleData{Percentage: &v1.DesiredPercentage{Value: 50}}, want: 5},
+		{name: "should compute desired expression.", annotations: map[string]string{"tier": "gold"}, current: 3,
+			data: v1.WorkloadScheduleData{Desired: 1, DesiredExpression: "workload.metadata.annotations['tier'] == 'gold' ? current * 2 : current"}, want: 6},
+		{name: "should return error when desired expression fails.", current: 3,
+			data: v1.WorkloadScheduleData{DesiredExpression: "int(workload.metadata.annotations['baseline'])"}, wantErr: true},
+		{name: "should return error for invalid baseline.", annotations: map[string]string{util.BaselineReplicasAnnotation: "ten"}, current: 3,
+			data: v1.WorkloadScheduleData{Percentage: &v1.DesiredPercentage{Value: 50}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := &core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			got, err := DesiredComputer{}.ComputeDesired(context.Background(), nil, workload, tt.current, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ComputeDesired() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ComputeDesired() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_AdjustReplicas_recordsBaseline(t *testing.T) {
+	deployment := newTestDeployment("dev", "api", 10, nil)
+	c := newTestClient(deployment)
+	w := New()
+
+	for _, want := range []int32{3, 3} {
+		_, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Percentage: &v1.DesiredPercentage{Value: 30}}}, c, context.Background())
+		if err != nil {
+			t.Fatalf("executeAction() error = %v", err)
+		}
+		if err = c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if *deployment.Spec.Replicas != want {
+			t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, want)
+		}
+		if got := deployment.Annotations[util.BaselineReplicasAnnotation]; got != "10" {
+			t.Errorf("executeAction() baseline = %v, want 10", got)
+		}
+	}
+}
+
+// failingListClient fails lists with options, as used to list the workloads selected by a workload schedule.
+type failingListClient struct {
+	client.Client
+}
+
+func (c *failingListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if len(opts) != 0 {
+		return fmt.Errorf("list failed")
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestWorkloadScheduleHandler_restoreBaselines(t *testing.T) {
+	tests := []struct {
+		name              string
+		restoreBaseline   string
+		workloadSchedules []v1.WorkloadScheduleData
+		// failScheduledList fails listing the workloads selected by the workload schedules.
+		failScheduledList bool
+		wantReplicas      int32
+		wantAnnotation    bool
+	}{
+		{name: "should restore baseline when no schedule applies.", restoreBaseline: "true", wantReplicas: 10},
+		{name: "should keep replicas when a schedule applies.", restoreBaseline: "true",
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 3}}, wantReplicas: 3, wantAnnotation: true},
+		{name: "should keep replicas and clear baseline when restoring is disabled.", restoreBaseline: "false", wantReplicas: 3},
+		{name: "should clear baseline once a schedule brings the workload back to it.", restoreBaseline: "false",
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 10}}, wantReplicas: 10},
+		{name: "should keep replicas when the desired state of a schedule fails.", restoreBaseline: "true",
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", DesiredExpression: "int(workload.metadata.labels['missing'])"}},
+			wantReplicas:      3, wantAnnotation: true},
+		{name: "should keep replicas when the workloads of a schedule fail to be listed.", restoreBaseline: "true", failScheduledList: true,
+			workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 3}}, wantReplicas: 3, wantAnnotation: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(config.RestoreBaseline, tt.restoreBaseline)
+			deployment := newTestDeployment("dev", "api", 3, nil)
+			deployment.Annotations = map[string]string{util.BaselineReplicasAnnotation: "10"}
+			c := newTestClient(deployment)
+			w := New()
+			w.trackKind(baselineKindsKey, util.DEPLOYMENT)
+			var r client.Client = c
+			if tt.failScheduledList {
+				r = &failingListClient{Client: c}
+			}
+			if _, err := w.executeAction(tt.workloadSchedules, r, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *deployment.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, tt.wantReplicas)
+			}
+			if _, got := deployment.Annotations[util.BaselineReplicasAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_restoreBaselines_unrecorded(t *testing.T) {
+	t.Setenv(config.RestoreBaseline, "true")
+	deployment := newTestDeployment("dev", "api", 3, nil)
+	deployment.Annotations = map[string]string{util.BaselineReplicasAnnotation: "10"}
+	c := &countingListClient{Client: newTestClient(deployment), lists: make(map[string]int)}
+	w := New()
+	if _, err := w.executeAction(nil, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if got := c.lists["DeploymentList"]; got != 0 {
+		t.Errorf("executeAction() listed deployments %d times, want none until a baseline is recorded", got)
+	}
+
+	if _, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 4}}, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if got := w.getTrackedKinds(baselineKindsKey); !reflect.DeepEqual(got, []string{util.DEPLOYMENT}) {
+		t.Errorf("executeAction() baseline kinds = %v, want %v", got, []string{util.DEPLOYMENT})
+	}
+}
+
+func TestWorkloadScheduleHandler_AdjustReplicas_reportsWorkloadErrors(t *testing.T) {
+	c := newTestClient(newTestDeployment("dev", "api", 2, nil), newTestDeployment("dev", "web", 2, map[string]string{"baseline": "4"}))
+	w := New()
+
+	statuses, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "*",
+		DesiredExpression: "int(workload.metadata.labels['baseline'])"}}, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if got := statuses["ws"].WorkloadErrors; len(got) != 1 || !strings.HasPrefix(got[0], "dev/deployment/api: ") {
+		t.Errorf("executeAction() workloadErrors = %v, want error of dev/deployment/api", got)
+	}
+	deployment := &apps.Deployment{}
+	if err = c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: "web"}, deployment); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *deployment.Spec.Replicas != 4 {
+		t.Errorf("executeAction() replicas = %v, want 4", *deployment.Spec.Replicas)
+	}
+}
@@ -0,0 +1,138 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ResourceQuotaHandler switches the hard limits of resourcequotas to the quota profile of the matched workload schedule,
+// the original hard limits are recorded on the resourcequota and restored once no quota profile applies to it.
+type ResourceQuotaHandler struct{}
+
+func NewResourceQuotaHandler() *ResourceQuotaHandler {
+	return &ResourceQuotaHandler{}
+}
+
+func (q *ResourceQuotaHandler) NewWorkload() client.Object {
+	return &core.ResourceQuota{}
+}
+
+func (q *ResourceQuotaHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var resourceQuotas core.ResourceQuotaList
+	if err := r.List(ctx, &resourceQuotas, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range resourceQuotas.Items {
+		workloads = append(workloads, &resourceQuotas.Items[idx])
+	}
+	return workloads, nil
+}
+
+// GetCurrent returns 0 when the resourcequota is switched to a quota profile, 1 otherwise.
+func (q *ResourceQuotaHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	if _, ok := workload.GetAnnotations()[util.OriginalQuotaAnnotation]; ok {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// ComputeDesired returns 0 when the matched workload schedule has a quota profile, 1 otherwise.
+func (q *ResourceQuotaHandler) ComputeDesired(_ context.Context, _ client.Client, _ client.Object, _ int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error) {
+	if _workloadSchedule.Quota != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (q *ResourceQuotaHandler) Apply(ctx context.Context, r client.Client, workload client.Object, _ int32) error {
+	return q.ApplyWorkloadSchedule(ctx, r, workload, 1, workloadschedulerv1.WorkloadScheduleData{})
+}
+
+func (q *ResourceQuotaHandler) InOrder(workload client.Object, _ int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) bool {
+	resourceQuota := workload.(*core.ResourceQuota)
+	original, recorded, err := getOriginalQuota(resourceQuota)
+	if err != nil {
+		return false
+	}
+	if _workloadSchedule.Quota == nil {
+		return !recorded
+	}
+	if !recorded {
+		return false
+	}
+	return equality.Semantic.DeepEqual(resourceQuota.Spec.Hard, withResourceList(original.DeepCopy(), _workloadSchedule.Quota))
+}
+
+func (q *ResourceQuotaHandler) ApplyWorkloadSchedule(ctx context.Context, r client.Client, workload client.Object, _ int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) error {
+	resourceQuota := workload.(*core.ResourceQuota)
+	original, recorded, err := getOriginalQuota(resourceQuota)
+	if err != nil {
+		return err
+	}
+	if _workloadSchedule.Quota == nil {
+		if recorded {
+			restoreQuota(resourceQuota, original)
+			return r.Update(ctx, resourceQuota)
+		}
+		return nil
+	}
+	if !recorded {
+		original = resourceQuota.Spec.Hard.DeepCopy()
+		originalHard, err := json.Marshal(original)
+		if err != nil {
+			return err
+		}
+		setAnnotation(resourceQuota, util.OriginalQuotaAnnotation, string(originalHard))
+	}
+	resourceQuota.Spec.Hard = withResourceList(original.DeepCopy(), _workloadSchedule.Quota)
+	return r.Update(ctx, resourceQuota)
+}
+
+// RestoreWorkloads restores the hard limits of resourcequotas switched by a workload schedule and not kept in this run.
+func (q *ResourceQuotaHandler) RestoreWorkloads(ctx context.Context, r client.Client, isKept func(workload client.Object) bool) error {
+	workloads, err := q.ListWorkloads(ctx, r)
+	if err != nil {
+		return err
+	}
+	for _, workload := range workloads {
+		resourceQuota := workload.(*core.ResourceQuota)
+		original, recorded, err := getOriginalQuota(resourceQuota)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to read original hard limits of resourcequota %s/%s.", resourceQuota.Namespace, resourceQuota.Name))
+			continue
+		}
+		if !recorded || isKept(workload) {
+			continue
+		}
+		restoreQuota(resourceQuota, original)
+		if err = r.Update(ctx, resourceQuota); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to restore resourcequota %s/%s.", resourceQuota.Namespace, resourceQuota.Name))
+		} else {
+			log.Log.Info(fmt.Sprintf("restored resourcequota NS: %v, Name: %v", resourceQuota.Namespace, resourceQuota.Name))
+		}
+	}
+	return nil
+}
+
+func restoreQuota(resourceQuota *core.ResourceQuota, original core.ResourceList) {
+	delete(resourceQuota.Annotations, util.OriginalQuotaAnnotation)
+	resourceQuota.Spec.Hard = original
+}
+
+func getOriginalQuota(resourceQuota *core.ResourceQuota) (core.ResourceList, bool, error) {
+	var original core.ResourceList
+	originalHard, ok := resourceQuota.Annotations[util.OriginalQuotaAnnotation]
+	if !ok {
+		return original, false, nil
+	}
+	err := json.Unmarshal([]byte(originalHard), &original)
+	return original, err == nil, err
+}
@@ -0,0 +1,64 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestWorkloadScheduleHandler_executeActionOnResourceQuota(t *testing.T) {
+	original := core.ResourceList{core.ResourceRequestsCPU: resource.MustParse("10"), core.ResourcePods: resource.MustParse("50")}
+	resourceQuota := &core.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "quota"}, Spec: core.ResourceQuotaSpec{Hard: original}}
+	c := newTestClient(resourceQuota)
+	w := New()
+
+	night := core.ResourceList{core.ResourceRequestsCPU: resource.MustParse("2")}
+	want := core.ResourceList{core.ResourceRequestsCPU: resource.MustParse("2"), core.ResourcePods: resource.MustParse("50")}
+	for range []int{0, 1} {
+		if _, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.QUOTA, Name: "*", Quota: night}}, c, context.Background()); err != nil {
+			t.Fatalf("executeAction() error = %v", err)
+		}
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(resourceQuota), resourceQuota); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if !equality.Semantic.DeepEqual(resourceQuota.Spec.Hard, want) {
+			t.Errorf("executeAction() hard = %v, want %v", resourceQuota.Spec.Hard, want)
+		}
+		if _, ok := resourceQuota.Annotations[util.OriginalQuotaAnnotation]; !ok {
+			t.Errorf("executeAction() annotation missing")
+		}
+	}
+
+	tests := []struct {
+		name              string
+		workloadSchedules []v1.WorkloadScheduleData
+	}{
+		{name: "should restore hard limits when the matched schedule has no quota profile.", workloadSchedules: []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.QUOTA, Name: "quota"}}},
+		{name: "should restore hard limits when no schedule applies."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			switched := resourceQuota.DeepCopy()
+			switched.ResourceVersion = ""
+			c := newTestClient(switched)
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(switched), switched); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !equality.Semantic.DeepEqual(switched.Spec.Hard, original) {
+				t.Errorf("executeAction() hard = %v, want %v", switched.Spec.Hard, original)
+			}
+			if _, ok := switched.Annotations[util.OriginalQuotaAnnotation]; ok {
+				t.Errorf("executeAction() annotation not removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// originalResources are the container requests and limits of a workload before a workload schedule resized it.
+type originalResources struct {
+	WorkloadSchedule string                               `json:"workloadSchedule"`
+	Containers       map[string]core.ResourceRequirements `json:"containers"`
+}
+
+func validateResourceProfile(profile workloadschedulerv1.ResourceProfile) error {
+	if profile.Percentage == nil && len(profile.Containers) == 0 {
+		return fmt.Errorf("percentage or containers need to be defined")
+	}
+	for _, container := range profile.Containers {
+		if len(container.Name) == 0 {
+			return fmt.Errorf("name of containers needs to be defined")
+		}
+	}
+	return nil
+}
+
+// getPodTemplate returns the pod template of workloads whose containers can be resized, nil for other workloads.
+func getPodTemplate(workload client.Object) *core.PodTemplateSpec {
+	switch _workload := workload.(type) {
+	case *apps.Deployment:
+		return &_workload.Spec.Template
+	case *apps.StatefulSet:
+		return &_workload.Spec.Template
+	}
+	return nil
+}
+
+// adjustResources sets the requests and limits of the containers of workload to the resource profile of the workload schedule,
+// computed from the original requests and limits which are recorded before the first change.
+func (w *WorkloadScheduleHandler) adjustResources(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, workload client.Object) error {
+	if getPodTemplate(workload) == nil {
+		return fmt.Errorf("resources apply to deployments and statefulsets only")
+	}
+	w.recordKind(r, ctx, resourceKindsKey, kind)
+	original, recorded, err := getOriginalResources(workload)
+	if err != nil {
+		return err
+	}
+	if !recorded {
+		original = originalResources{Containers: make(map[string]core.ResourceRequirements)}
+		for _, container := range getPodTemplate(workload).Spec.Containers {
+			original.Containers[container.Name] = container.Resources
+		}
+	}
+	original.WorkloadSchedule = _workloadSchedule.WorkloadScheduler
+	originalContainers, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+
+	updated := workload.DeepCopyObject().(client.Object)
+	setAnnotation(updated, util.OriginalResourcesAnnotation, string(originalContainers))
+	containers := getPodTemplate(updated).Spec.Containers
+	for idx := range containers {
+		resources, ok := original.Containers[containers[idx].Name]
+		if !ok {
+			resources = containers[idx].Resources
+		}
+		containers[idx].Resources = computeResources(resources, containers[idx].Name, *_workloadSchedule.Resources)
+	}
+
+	if equality.Semantic.DeepEqual(workload, updated) {
+		log.Log.Info(fmt.Sprintf("got resources of %s %s in order with %s. Namespace: %s", workload.GetName(), kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace()))
+		return nil
+	}
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	if w.isDeferred(_workloadSchedule, pass, workload, processedWorkloadKey, "resize") {
+		return nil
+	}
+	if err = r.Update(ctx, updated); err != nil {
+		return err
+	}
+	log.Log.Info(fmt.Sprintf("%v resized NS: %v, Name: %v", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+	pass.countChange(workload, processedWorkloadKey)
+	// updated holds the resourceVersion written by the server, the cache may still hold the workload before the update.
+	reflect.ValueOf(workload).Elem().Set(reflect.ValueOf(updated).Elem())
+	return nil
+}
+
+// computeResources returns the requests and limits of the container for the resource profile.
+func computeResources(resources core.ResourceRequirements, name string, profile workloadschedulerv1.ResourceProfile) core.ResourceRequirements {
+	resources = *resources.DeepCopy()
+	if profile.Percentage != nil {
+		resources.Requests = scaleResourceList(resources.Requests, *profile.Percentage)
+		resources.Limits = scaleResourceList(resources.Limits, *profile.Percentage)
+	}
+	for _, container := range profile.Containers {
+		if container.Name != name {
+			continue
+		}
+		resources.Requests = withResourceList(resources.Requests, container.Requests)
+		resources.Limits = withResourceList(resources.Limits, container.Limits)
+	}
+	return resources
+}
+
+// scaleResourceList returns resourceList at percentage, cpu is rounded up to the millicore and other resources to the unit.
+func scaleResourceList(resourceList core.ResourceList, percentage int32) core.ResourceList {
+	if resourceList == nil {
+		return nil
+	}
+	scaled := make(core.ResourceList)
+	for name, quantity := range resourceList {
+		if name == core.ResourceCPU {
+			scaled[name] = *resource.NewMilliQuantity(ceilPercentage(quantity.MilliValue(), percentage), quantity.Format)
+		} else {
+			scaled[name] = *resource.NewQuantity(ceilPercentage(quantity.Value(), percentage), quantity.Format)
+		}
+	}
+	return scaled
+}
+
+func ceilPercentage(value int64, percentage int32) int64 {
+	return (value*int64(percentage) + 99) / 100
+}
+
+func withResourceList(resourceList core.ResourceList, overrides core.ResourceList) core.ResourceList {
+	if len(overrides) == 0 {
+		return resourceList
+	}
+	if resourceList == nil {
+		resourceList = make(core.ResourceList)
+	}
+	for name, quantity := range overrides {
+		resourceList[name] = quantity
+	}
+	return resourceList
+}
+
+// restoreResources restores the original requests and limits of workloads no workload schedule resized in this run, only
+// workloads of the kinds recorded as resized are listed.
+func (w *WorkloadScheduleHandler) restoreResources(r client.Client, ctx context.Context, pass *actionPass) {
+	for _, kind := range w.getTrackedKinds(resourceKindsKey) {
+		handler, ok := w.WorkloadHandlers[kind]
+		if !ok {
+			continue
+		}
+		workloads, err := handler.ListWorkloads(ctx, r)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("error occurred when fetching %s", kind))
+			continue
+		}
+		for _, workload := range workloads {
+			if getPodTemplate(workload) == nil {
+				continue
+			}
+			if !pass.isRestorable(fmt.Sprintf("%s/%s/%s/resources", workload.GetNamespace(), kind, workload.GetName()), kind) {
+				continue
+			}
+			original, recorded, err := getOriginalResources(workload)
+			if err != nil {
+				log.Log.Error(err, fmt.Sprintf("failed to read original resources of %s %s/%s.", kind, workload.GetNamespace(), workload.GetName()))
+				continue
+			}
+			if !recorded {
+				continue
+			}
+			processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+			if w.isRestoreDeferred(pass, workload, processedWorkloadKey) {
+				continue
+			}
+			annotations := workload.GetAnnotations()
+			delete(annotations, util.OriginalResourcesAnnotation)
+			workload.SetAnnotations(annotations)
+			containers := getPodTemplate(workload).Spec.Containers
+			for idx := range containers {
+				if resources, ok := original.Containers[containers[idx].Name]; ok {
+					containers[idx].Resources = resources
+				}
+			}
+			if err = r.Update(ctx, workload); err != nil {
+				log.Log.Error(err, fmt.Sprintf("failed to restore resources of %s %s/%s resized by workloadschedule %s.", kind, workload.GetNamespace(), workload.GetName(), original.WorkloadSchedule))
+			} else {
+				log.Log.Info(fmt.Sprintf("restored resources of %s NS: %v, Name: %v resized by %v", kind, workload.GetNamespace(), workload.GetName(), original.WorkloadSchedule))
+				pass.countChange(workload, processedWorkloadKey)
+			}
+		}
+	}
+}
+
+func getOriginalResources(workload client.Object) (originalResources, bool, error) {
+	var original originalResources
+	originalContainers, ok := workload.GetAnnotations()[util.OriginalResourcesAnnotation]
+	if !ok {
+		return original, false, nil
+	}
+	err := json.Unmarshal([]byte(originalContainers), &original)
+	return original, err == nil, err
+}
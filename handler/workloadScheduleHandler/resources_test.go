@@ -0,0 +1,199 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestComputeResources(t *testing.T) {
+	resources := core.ResourceRequirements{
+		Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("500m"), core.ResourceMemory: resource.MustParse("256Mi")},
+		Limits:   core.ResourceList{core.ResourceCPU: resource.MustParse("1"), core.ResourceMemory: resource.MustParse("512Mi")},
+	}
+	tests := []struct {
+		name    string
+		profile v1.ResourceProfile
+		want    core.ResourceRequirements
+	}{
+		{name: "should scale requests and limits by percentage.", profile: v1.ResourceProfile{Percentage: pointer.Int32(50)}, want: core.ResourceRequirements{
+			Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("250m"), core.ResourceMemory: resource.MustParse("128Mi")},
+			Limits:   core.ResourceList{core.ResourceCPU: resource.MustParse("500m"), core.ResourceMemory: resource.MustParse("256Mi")},
+		}},
+		{name: "should round percentage up.", profile: v1.ResourceProfile{Percentage: pointer.Int32(33)}, want: core.ResourceRequirements{
+			Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("165m"), core.ResourceMemory: resource.MustParse("88583701")},
+			Limits:   core.ResourceList{core.ResourceCPU: resource.MustParse("330m"), core.ResourceMemory: resource.MustParse("177167401")},
+		}},
+		{name: "should set resources of container over percentage.", profile: v1.ResourceProfile{Percentage: pointer.Int32(50), Containers: []v1.ContainerResources{
+			{Name: "api", Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")}},
+		}}, want: core.ResourceRequirements{
+			Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m"), core.ResourceMemory: resource.MustParse("128Mi")},
+			Limits:   core.ResourceList{core.ResourceCPU: resource.MustParse("500m"), core.ResourceMemory: resource.MustParse("256Mi")},
+		}},
+		{name: "should ignore resources of other containers.", profile: v1.ResourceProfile{Containers: []v1.ContainerResources{
+			{Name: "sidecar", Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")}},
+		}}, want: resources},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeResources(resources, "api", tt.profile); !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("computeResources() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResourceProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile v1.ResourceProfile
+		wantErr bool
+	}{
+		{name: "should accept percentage.", profile: v1.ResourceProfile{Percentage: pointer.Int32(50)}},
+		{name: "should fail when profile is empty.", profile: v1.ResourceProfile{}, wantErr: true},
+		{name: "should fail when container name is empty.", profile: v1.ResourceProfile{Containers: []v1.ContainerResources{{}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateResourceProfile(tt.profile); (err != nil) != tt.wantErr {
+				t.Errorf("validateResourceProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_adjustResources(t *testing.T) {
+	original := core.ResourceRequirements{Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("1")}}
+	deployment := newTestDeployment("dev", "api", 1, nil)
+	deployment.Spec.Template.Spec.Containers = []core.Container{{Name: "api", Resources: original}}
+	c := newTestClient(deployment)
+	w := New()
+
+	for _, want := range []string{"500m", "500m"} {
+		statuses, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 1,
+			Resources: &v1.ResourceProfile{Percentage: pointer.Int32(50)}}}, c, context.Background())
+		if err != nil {
+			t.Fatalf("executeAction() error = %v", err)
+		}
+		if status, ok := statuses["ws"]; ok && len(status.WorkloadErrors) != 0 {
+			t.Fatalf("executeAction() workloadErrors = %v", status.WorkloadErrors)
+		}
+		if err = c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got := deployment.Spec.Template.Spec.Containers[0].Resources.Requests[core.ResourceCPU]; got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("executeAction() cpu = %v, want %v", got.String(), want)
+		}
+		if _, ok := deployment.Annotations[util.OriginalResourcesAnnotation]; !ok {
+			t.Errorf("executeAction() annotation missing")
+		}
+	}
+
+	// resources are kept while the scale up of the workload waits for a missing dependency.
+	if _, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 2,
+		Resources: &v1.ResourceProfile{Percentage: pointer.Int32(50)}, Dependencies: []v1.WorkloadDependency{{Workload: v1.WorkloadReference{Name: "api"}, DependsOn: []v1.WorkloadReference{{Name: "db"}}}}}}, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Resources.Requests[core.ResourceCPU]; got.Cmp(resource.MustParse("500m")) != 0 || *deployment.Spec.Replicas != 1 {
+		t.Errorf("executeAction() waiting cpu = %v, replicas = %v, want 500m and 1", got.String(), *deployment.Spec.Replicas)
+	}
+
+	if _, err := w.executeAction(nil, c, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Resources; !equality.Semantic.DeepEqual(got, original) {
+		t.Errorf("executeAction() restored resources = %v, want %v", got, original)
+	}
+	if _, ok := deployment.Annotations[util.OriginalResourcesAnnotation]; ok {
+		t.Errorf("executeAction() annotation not removed")
+	}
+}
+
+// staleGetClient gets objects as they were created, as a cache not synced with the updates yet.
+type staleGetClient struct {
+	client.Client
+	created map[client.ObjectKey]client.Object
+}
+
+func (c *staleGetClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if created, ok := c.created[key]; ok {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(created.DeepCopyObject()).Elem())
+		return nil
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func TestWorkloadScheduleHandler_adjustResources_scales(t *testing.T) {
+	deployment := newTestDeployment("dev", "api", 1, nil)
+	deployment.Spec.Template.Spec.Containers = []core.Container{{Name: "api", Resources: core.ResourceRequirements{Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("1")}}}}
+	// the baseline is recorded by an earlier change, so the workload is not patched between the resize and the scale.
+	deployment.Annotations = map[string]string{util.BaselineReplicasAnnotation: "1"}
+	c := newTestClient(deployment)
+	created := &apps.Deployment{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), created); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	w := New()
+
+	if _, err := w.executeAction([]v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 3,
+		Resources: &v1.ResourceProfile{Percentage: pointer.Int32(50)}}}, &staleGetClient{Client: c, created: map[client.ObjectKey]client.Object{client.ObjectKeyFromObject(deployment): created}}, context.Background()); err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if *deployment.Spec.Replicas != 3 {
+		t.Errorf("executeAction() replicas = %v, want 3", *deployment.Spec.Replicas)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Resources.Requests[core.ResourceCPU]; got.String() != "500m" {
+		t.Errorf("executeAction() cpu = %v, want 500m", got.String())
+	}
+}
+
+func TestWorkloadScheduleHandler_restoreResources(t *testing.T) {
+	tests := []struct {
+		name           string
+		recorded       bool
+		wantCPU        string
+		wantAnnotation bool
+	}{
+		{name: "should restore resources of a recorded kind.", recorded: true, wantCPU: "1"},
+		{name: "should not list workloads of a kind not recorded as resized.", wantCPU: "500m", wantAnnotation: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := newTestDeployment("dev", "api", 1, nil)
+			deployment.Annotations = map[string]string{util.OriginalResourcesAnnotation: `{"workloadSchedule":"ws","containers":{"api":{"requests":{"cpu":"1"}}}}`}
+			deployment.Spec.Template.Spec.Containers = []core.Container{{Name: "api", Resources: core.ResourceRequirements{Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("500m")}}}}
+			c := newTestClient(deployment)
+			w := New()
+			if tt.recorded {
+				w.trackKind(resourceKindsKey, util.DEPLOYMENT)
+			}
+			w.restoreResources(c, context.Background(), newActionPass())
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := deployment.Spec.Template.Spec.Containers[0].Resources.Requests[core.ResourceCPU]; got.Cmp(resource.MustParse(tt.wantCPU)) != 0 {
+				t.Errorf("restoreResources() cpu = %v, want %v", got.String(), tt.wantCPU)
+			}
+			if _, ok := deployment.Annotations[util.OriginalResourcesAnnotation]; ok != tt.wantAnnotation {
+				t.Errorf("restoreResources() annotation = %v, want %v", ok, tt.wantAnnotation)
+			}
+		})
+	}
+}
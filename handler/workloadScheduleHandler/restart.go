@@ -0,0 +1,57 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"time"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout restart sets.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// restartWorkload restarts the workload once per occurrence of the schedule, the occurrence is recorded on the workload
+// so it is not restarted again for it, even by another instance of the operator.
+func (w *WorkloadScheduleHandler) restartWorkload(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, workload client.Object) {
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s/restart", workload.GetNamespace(), kind, workload.GetName())
+	if _, ok := pass.processedWorkloads[processedWorkloadKey]; ok {
+		return
+	}
+	pass.processedWorkloads[processedWorkloadKey] = processedWorkloadKey
+
+	if getPodTemplate(workload) == nil {
+		log.Log.Error(fmt.Errorf("restart applies to deployments and statefulsets only"), fmt.Sprintf("failed to restart %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+		w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s/%s/%s: restart applies to deployments and statefulsets only", workload.GetNamespace(), kind, workload.GetName()))
+		return
+	}
+	if workload.GetAnnotations()[util.RestartedOccurrenceAnnotation] == _workloadSchedule.Occurrence {
+		if w.Config.LookUpBooleanEnv(config.Debug) {
+			log.Log.Info(fmt.Sprintf("already restarted %s %s for %s. Namespace: %s", workload.GetName(), kind, _workloadSchedule.Occurrence, workload.GetNamespace()))
+		}
+		return
+	}
+
+	workloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	if w.isDeferred(_workloadSchedule, pass, workload, workloadKey, "restart") {
+		return
+	}
+
+	updated := workload.DeepCopyObject().(client.Object)
+	podTemplate := getPodTemplate(updated)
+	if podTemplate.Annotations == nil {
+		podTemplate.Annotations = make(map[string]string)
+	}
+	podTemplate.Annotations[restartedAtAnnotation] = time.Now().Format(time.RFC3339)
+	setAnnotation(updated, util.RestartedOccurrenceAnnotation, _workloadSchedule.Occurrence)
+	if err := r.Update(ctx, updated); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to restart %s for workloadschedule %s. Namespace: %s, Name: %s", kind, _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+		w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(_workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s/%s/%s: %v", workload.GetNamespace(), kind, workload.GetName(), err))
+		return
+	}
+	log.Log.Info(fmt.Sprintf("%v restarted NS: %v, Name: %v for %v", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), _workloadSchedule.Occurrence))
+	pass.countChange(workload, workloadKey)
+}
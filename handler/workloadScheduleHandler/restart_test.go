@@ -0,0 +1,74 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+func TestWorkloadScheduleHandler_restartWorkload(t *testing.T) {
+	tests := []struct {
+		name              string
+		restartedAt       string
+		occurrence        string
+		workloadSchedules []v1.WorkloadScheduleData
+		wantRestarted     bool
+		wantReplicas      int32
+	}{
+		{name: "should restart workload for a new occurrence without changing replicas.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionRestart, Occurrence: "nightly@2023-06-02T03:00:00Z"},
+		}, occurrence: "nightly@2023-06-01T03:00:00Z", restartedAt: "2023-06-01T03:00:00Z", wantRestarted: true, wantReplicas: 2},
+		{name: "should not restart workload again for the same occurrence.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionRestart, Occurrence: "nightly@2023-06-01T03:00:00Z"},
+		}, occurrence: "nightly@2023-06-01T03:00:00Z", restartedAt: "2023-06-01T03:00:00Z", wantReplicas: 2},
+		{name: "should restart workload scaled by another workload schedule.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "scale", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 1},
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Action: v1.ActionRestart, Occurrence: "nightly@2023-06-02T03:00:00Z"},
+		}, wantRestarted: true, wantReplicas: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := newTestDeployment("dev", "api", 2, nil)
+			if len(tt.occurrence) != 0 {
+				deployment.Annotations = map[string]string{util.RestartedOccurrenceAnnotation: tt.occurrence}
+				deployment.Spec.Template.Annotations = map[string]string{restartedAtAnnotation: tt.restartedAt}
+			}
+			c := newTestClient(deployment)
+			w := New()
+			if _, err := w.executeAction(tt.workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if restarted := deployment.Spec.Template.Annotations[restartedAtAnnotation] != tt.restartedAt; restarted != tt.wantRestarted {
+				t.Errorf("executeAction() restarted = %v, want %v", restarted, tt.wantRestarted)
+			}
+			if got := deployment.Annotations[util.RestartedOccurrenceAnnotation]; got != "nightly@2023-06-02T03:00:00Z" && tt.wantRestarted {
+				t.Errorf("executeAction() occurrence = %v, want nightly@2023-06-02T03:00:00Z", got)
+			}
+			if *deployment.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *deployment.Spec.Replicas, tt.wantReplicas)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_BuildSpecMap_restartOccurrence(t *testing.T) {
+	w := &WorkloadScheduleHandler{}
+	specMap := map[string]map[string][]v1.WorkloadScheduleData{}
+	workloadSchedule := v1.WorkloadSchedule{ObjectMeta: metav1.ObjectMeta{Name: "ws"}, Spec: v1.WorkloadScheduleSpec{
+		Schedules: []v1.WorkloadScheduleUnit{{Schedule: "nightly", Action: v1.ActionRestart}},
+	}}
+	startTime := time.Date(2023, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	w.BuildSpecMap(workloadSchedule, specMap, v1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "nightly"}}, startTime, startTime)
+	data := specMap["0100"]["*/deployment/*"]
+	if len(data) != 1 || data[0].Action != v1.ActionRestart || data[0].Occurrence != "nightly@2023-06-01T03:00:00Z" {
+		t.Errorf("BuildSpecMap() got = %v, want restart of occurrence nightly@2023-06-01T03:00:00Z", data)
+	}
+}
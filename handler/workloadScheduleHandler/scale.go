@@ -0,0 +1,82 @@
+package workloadScheduleHandler
+
+import (
+	"context"
+	autoscaling "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const scaleSubResource = "scale"
+
+// ScaleHandler adjusts replicas of any workload exposing the scale subresource e.g. ReplicaSet, Argo Rollout or Cluster API MachineDeployment.
+type ScaleHandler struct {
+	DesiredComputer
+	*meta.RESTMapping
+}
+
+func NewScaleHandler(mapping *meta.RESTMapping) *ScaleHandler {
+	return &ScaleHandler{RESTMapping: mapping}
+}
+
+// ResolveKind maps a Kind.version.group, Kind.group or Kind.version (core group) reference to its REST mapping using discovery.
+func ResolveKind(mapper meta.RESTMapper, kind string) (*meta.RESTMapping, error) {
+	fullySpecifiedGVK, groupKind := schema.ParseKindArg(kind)
+	if fullySpecifiedGVK != nil {
+		if mapping, err := mapper.RESTMapping(fullySpecifiedGVK.GroupKind(), fullySpecifiedGVK.Version); err == nil {
+			return mapping, nil
+		}
+	}
+	mapping, err := mapper.RESTMapping(groupKind)
+	if err != nil && meta.IsNoMatchError(err) && !strings.Contains(groupKind.Group, ".") {
+		if coreMapping, coreErr := mapper.RESTMapping(schema.GroupKind{Kind: groupKind.Kind}, groupKind.Group); coreErr == nil {
+			return coreMapping, nil
+		}
+	}
+	return mapping, err
+}
+
+func (s *ScaleHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var scalableWorkloads unstructured.UnstructuredList
+	scalableWorkloads.SetGroupVersionKind(s.GroupVersionKind.GroupVersion().WithKind(s.GroupVersionKind.Kind + "List"))
+	if err := r.List(ctx, &scalableWorkloads, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range scalableWorkloads.Items {
+		workloads = append(workloads, &scalableWorkloads.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (s *ScaleHandler) GetCurrent(ctx context.Context, r client.Client, workload client.Object) (int32, error) {
+	scale, err := s.getScale(ctx, r, workload)
+	if err != nil {
+		return 0, err
+	}
+	replicas, _, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	return int32(replicas), err
+}
+
+func (s *ScaleHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	scale, err := s.getScale(ctx, r, workload)
+	if err != nil {
+		return err
+	}
+	if err = unstructured.SetNestedField(scale.Object, int64(desired), "spec", "replicas"); err != nil {
+		return err
+	}
+	return r.SubResource(scaleSubResource).Update(ctx, workload, client.WithSubResourceBody(scale))
+}
+
+func (s *ScaleHandler) getScale(ctx context.Context, r client.Client, workload client.Object) (*unstructured.Unstructured, error) {
+	scale := &unstructured.Unstructured{}
+	scale.SetGroupVersionKind(autoscaling.SchemeGroupVersion.WithKind("Scale"))
+	if err := r.SubResource(scaleSubResource).Get(ctx, workload, scale); err != nil {
+		return nil, err
+	}
+	return scale, nil
+}
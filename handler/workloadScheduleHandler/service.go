@@ -0,0 +1,176 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// originalService are the fields of a LoadBalancer service before a workload schedule hibernated it.
+type originalService struct {
+	Type                          core.ServiceType                  `json:"type"`
+	LoadBalancerIP                string                            `json:"loadBalancerIP,omitempty"`
+	LoadBalancerSourceRanges      []string                          `json:"loadBalancerSourceRanges,omitempty"`
+	LoadBalancerClass             *string                           `json:"loadBalancerClass,omitempty"`
+	ExternalTrafficPolicy         core.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+	HealthCheckNodePort           int32                             `json:"healthCheckNodePort,omitempty"`
+	AllocateLoadBalancerNodePorts *bool                             `json:"allocateLoadBalancerNodePorts,omitempty"`
+	// NodePorts are the node ports of the ports of the service by index.
+	NodePorts   []int32           `json:"nodePorts,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ServiceHandler hibernates LoadBalancer services when desired is 0 by converting them to ClusterIP services, releasing their
+// load balancer. The original fields and annotations are recorded on the service and restored when desired is above 0 or
+// once no workload schedule applies to it.
+type ServiceHandler struct {
+	DesiredComputer
+}
+
+func NewServiceHandler() *ServiceHandler {
+	return &ServiceHandler{}
+}
+
+func (s *ServiceHandler) NewWorkload() client.Object {
+	return &core.Service{}
+}
+
+// ListWorkloads returns the LoadBalancer services and the services hibernated by a workload schedule.
+func (s *ServiceHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var services core.ServiceList
+	if err := r.List(ctx, &services, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range services.Items {
+		if _, ok := services.Items[idx].Annotations[util.OriginalServiceAnnotation]; ok || services.Items[idx].Spec.Type == core.ServiceTypeLoadBalancer {
+			workloads = append(workloads, &services.Items[idx])
+		}
+	}
+	return workloads, nil
+}
+
+// GetCurrent returns 0 when the service is hibernated, 1 otherwise.
+func (s *ServiceHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	if _, ok := workload.GetAnnotations()[util.OriginalServiceAnnotation]; ok {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (s *ServiceHandler) ComputeDesired(ctx context.Context, r client.Client, workload client.Object, current int32, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (int32, error) {
+	desired, err := s.DesiredComputer.ComputeDesired(ctx, r, workload, current, _workloadSchedule)
+	if err != nil || desired == 0 {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (s *ServiceHandler) TogglesState() bool {
+	return true
+}
+
+func (s *ServiceHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	service := workload.(*core.Service)
+	if desired == 0 {
+		if err := hibernateService(service); err != nil {
+			return err
+		}
+	} else if err := restoreService(service); err != nil {
+		return err
+	}
+	return r.Update(ctx, service)
+}
+
+// InOrder compares the current state with desired, services keep no baseline replicas.
+func (s *ServiceHandler) InOrder(workload client.Object, desired int32, _ workloadschedulerv1.WorkloadScheduleData) bool {
+	current, _ := s.GetCurrent(context.Background(), nil, workload)
+	return current == desired
+}
+
+func (s *ServiceHandler) ApplyWorkloadSchedule(ctx context.Context, r client.Client, workload client.Object, desired int32, _ workloadschedulerv1.WorkloadScheduleData) error {
+	return s.Apply(ctx, r, workload, desired)
+}
+
+// RestoreWorkloads restores the services hibernated by a workload schedule and not kept in this run.
+func (s *ServiceHandler) RestoreWorkloads(ctx context.Context, r client.Client, isKept func(workload client.Object) bool) error {
+	workloads, err := s.ListWorkloads(ctx, r)
+	if err != nil {
+		return err
+	}
+	for _, workload := range workloads {
+		if _, ok := workload.GetAnnotations()[util.OriginalServiceAnnotation]; !ok || isKept(workload) {
+			continue
+		}
+		if err = s.Apply(ctx, r, workload, 1); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to restore service %s/%s.", workload.GetNamespace(), workload.GetName()))
+		} else {
+			log.Log.Info(fmt.Sprintf("restored service NS: %v, Name: %v", workload.GetNamespace(), workload.GetName()))
+		}
+	}
+	return nil
+}
+
+func hibernateService(service *core.Service) error {
+	if service.Spec.Type != core.ServiceTypeLoadBalancer {
+		return nil
+	}
+	original := originalService{
+		Type:                          service.Spec.Type,
+		LoadBalancerIP:                service.Spec.LoadBalancerIP,
+		LoadBalancerSourceRanges:      service.Spec.LoadBalancerSourceRanges,
+		LoadBalancerClass:             service.Spec.LoadBalancerClass,
+		ExternalTrafficPolicy:         service.Spec.ExternalTrafficPolicy,
+		HealthCheckNodePort:           service.Spec.HealthCheckNodePort,
+		AllocateLoadBalancerNodePorts: service.Spec.AllocateLoadBalancerNodePorts,
+		Annotations:                   service.Annotations,
+	}
+	for idx := range service.Spec.Ports {
+		original.NodePorts = append(original.NodePorts, service.Spec.Ports[idx].NodePort)
+		service.Spec.Ports[idx].NodePort = 0
+	}
+	originalFields, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	setAnnotation(service, util.OriginalServiceAnnotation, string(originalFields))
+
+	service.Spec.Type = core.ServiceTypeClusterIP
+	service.Spec.LoadBalancerIP = ""
+	service.Spec.LoadBalancerSourceRanges = nil
+	service.Spec.LoadBalancerClass = nil
+	service.Spec.ExternalTrafficPolicy = ""
+	service.Spec.HealthCheckNodePort = 0
+	service.Spec.AllocateLoadBalancerNodePorts = nil
+	return nil
+}
+
+func restoreService(service *core.Service) error {
+	originalFields, ok := service.Annotations[util.OriginalServiceAnnotation]
+	if !ok {
+		return nil
+	}
+	var original originalService
+	if err := json.Unmarshal([]byte(originalFields), &original); err != nil {
+		return fmt.Errorf("invalid %s annotation. %v", util.OriginalServiceAnnotation, err)
+	}
+	service.Spec.Type = original.Type
+	service.Spec.LoadBalancerIP = original.LoadBalancerIP
+	service.Spec.LoadBalancerSourceRanges = original.LoadBalancerSourceRanges
+	service.Spec.LoadBalancerClass = original.LoadBalancerClass
+	service.Spec.ExternalTrafficPolicy = original.ExternalTrafficPolicy
+	service.Spec.HealthCheckNodePort = original.HealthCheckNodePort
+	service.Spec.AllocateLoadBalancerNodePorts = original.AllocateLoadBalancerNodePorts
+	for idx := range service.Spec.Ports {
+		if idx < len(original.NodePorts) {
+			service.Spec.Ports[idx].NodePort = original.NodePorts[idx]
+		}
+	}
+	service.Annotations = original.Annotations
+	return nil
+}
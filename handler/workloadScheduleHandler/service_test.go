@@ -0,0 +1,70 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestServiceHandler(t *testing.T) {
+	loadBalancer := func() *core.Service {
+		return &core.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "ingress", Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"}},
+			Spec: core.ServiceSpec{Type: core.ServiceTypeLoadBalancer, LoadBalancerIP: "10.0.0.10", ExternalTrafficPolicy: core.ServiceExternalTrafficPolicyLocal,
+				HealthCheckNodePort: 32000, AllocateLoadBalancerNodePorts: pointer.Bool(true), Ports: []core.ServicePort{{Name: "http", Port: 80, NodePort: 31080}}}}
+	}
+	tests := []struct {
+		name              string
+		workloadSchedules []v1.WorkloadScheduleData
+		rounds            int
+		wantType          core.ServiceType
+		wantAnnotation    bool
+	}{
+		{name: "should convert load balancer to cluster ip when desired is 0.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.SERVICE, Name: "*", Desired: 0}}, rounds: 1, wantType: core.ServiceTypeClusterIP, wantAnnotation: true},
+		{name: "should restore load balancer when desired is above 0.", workloadSchedules: []v1.WorkloadScheduleData{
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.SERVICE, Name: "*", Desired: 1}}, rounds: 2, wantType: core.ServiceTypeLoadBalancer},
+		{name: "should restore load balancer when no schedule applies.", rounds: 2, wantType: core.ServiceTypeLoadBalancer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(loadBalancer())
+			w := New()
+			// the first round hibernates the service, the following rounds apply the workload schedules of the test.
+			for round := 0; round < tt.rounds; round++ {
+				workloadSchedules := tt.workloadSchedules
+				if round == 0 && tt.rounds > 1 {
+					workloadSchedules = []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.SERVICE, Name: "ingress", Desired: 0}}
+				}
+				if _, err := w.executeAction(workloadSchedules, c, context.Background()); err != nil {
+					t.Fatalf("executeAction() error = %v", err)
+				}
+			}
+			service := &core.Service{}
+			if err := c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: "ingress"}, service); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if service.Spec.Type != tt.wantType {
+				t.Errorf("executeAction() type = %v, want %v", service.Spec.Type, tt.wantType)
+			}
+			if _, got := service.Annotations[util.OriginalServiceAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("executeAction() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+			if tt.wantType == core.ServiceTypeClusterIP {
+				if service.Spec.LoadBalancerIP != "" || service.Spec.ExternalTrafficPolicy != "" || service.Spec.HealthCheckNodePort != 0 || service.Spec.Ports[0].NodePort != 0 {
+					t.Errorf("executeAction() spec = %v, want load balancer fields cleared", service.Spec)
+				}
+				return
+			}
+			want := loadBalancer()
+			if !reflect.DeepEqual(service.Spec, want.Spec) || !reflect.DeepEqual(service.Annotations, want.Annotations) {
+				t.Errorf("executeAction() service = %v %v, want %v %v", service.Annotations, service.Spec, want.Annotations, want.Spec)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"fmt"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"time"
+)
+
+// defaultSnapshotRetention is the number of snapshots kept per persistentvolumeclaim when the policy sets none.
+const defaultSnapshotRetention = 3
+
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
+
+// snapshotVolumes takes a VolumeSnapshot of each persistentvolumeclaim of the statefulset, it returns true once all the
+// snapshots of the round are ready to use, the round is recorded on the statefulset so following runs wait for the same snapshots.
+// A failed snapshot ends the round, the next run takes new snapshots.
+func snapshotVolumes(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet, policy workloadschedulerv1.VolumeSnapshotPolicy) (bool, error) {
+	if _, err := r.RESTMapper().RESTMapping(volumeSnapshotGVK.GroupKind(), volumeSnapshotGVK.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, fmt.Errorf("volumesnapshots are not served by the cluster")
+		}
+		return false, err
+	}
+	claims, err := getPersistentVolumeClaims(ctx, r, statefulSet)
+	if err != nil || len(claims) == 0 {
+		return err == nil, err
+	}
+
+	round, ok := statefulSet.Annotations[util.SnapshotRoundAnnotation]
+	if !ok {
+		round = time.Now().UTC().Format("20060102150405")
+		patch := client.MergeFrom(statefulSet.DeepCopy())
+		setAnnotation(statefulSet, util.SnapshotRoundAnnotation, round)
+		if err = r.Patch(ctx, statefulSet, patch); err != nil {
+			return false, err
+		}
+	}
+
+	snapshots, err := listVolumeSnapshots(ctx, r, statefulSet, client.MatchingLabels{util.SnapshotStatefulSetLabel: statefulSet.Name, util.SnapshotRoundLabel: round})
+	if err != nil {
+		return false, err
+	}
+	taken := map[string]*unstructured.Unstructured{}
+	for idx := range snapshots {
+		claim, _, _ := unstructured.NestedString(snapshots[idx].Object, "spec", "source", "persistentVolumeClaimName")
+		taken[claim] = &snapshots[idx]
+	}
+
+	ready := true
+	for _, claim := range claims {
+		snapshot, ok := taken[claim]
+		if !ok {
+			if err = createVolumeSnapshot(ctx, r, statefulSet, claim, round, policy); err != nil && !errors.IsAlreadyExists(err) {
+				return false, err
+			}
+			log.Log.Info(fmt.Sprintf("taking snapshot of persistentvolumeclaim NS: %v, Name: %v", statefulSet.Namespace, claim))
+			ready = false
+			continue
+		}
+		if message, failed, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); failed {
+			return false, endSnapshotRound(ctx, r, statefulSet, snapshots, fmt.Errorf("snapshot %s of persistentvolumeclaim %s failed. %s", snapshot.GetName(), claim, message))
+		}
+		if readyToUse, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse"); !readyToUse {
+			ready = false
+		}
+	}
+	if !ready {
+		return false, nil
+	}
+
+	if err = clearSnapshotRound(ctx, r, statefulSet); err != nil {
+		return false, err
+	}
+	pruneVolumeSnapshots(ctx, r, statefulSet, policy)
+	return true, nil
+}
+
+// getPersistentVolumeClaims returns the names of the existing persistentvolumeclaims of the volume claim templates of the
+// current replicas of the statefulset, numbered from spec.ordinals.start.
+func getPersistentVolumeClaims(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet) ([]string, error) {
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	start := int32(0)
+	if statefulSet.Spec.Ordinals != nil {
+		start = statefulSet.Spec.Ordinals.Start
+	}
+	var claims []string
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		for ordinal := start; ordinal < start+replicas; ordinal++ {
+			name := fmt.Sprintf("%s-%s-%d", template.Name, statefulSet.Name, ordinal)
+			if err := r.Get(ctx, client.ObjectKey{Namespace: statefulSet.Namespace, Name: name}, &core.PersistentVolumeClaim{}); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			claims = append(claims, name)
+		}
+	}
+	return claims, nil
+}
+
+func createVolumeSnapshot(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet, claim string, round string, policy workloadschedulerv1.VolumeSnapshotPolicy) error {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetNamespace(statefulSet.Namespace)
+	snapshot.SetName(fmt.Sprintf("%s-%s", claim, round))
+	snapshot.SetLabels(map[string]string{util.SnapshotStatefulSetLabel: statefulSet.Name, util.SnapshotRoundLabel: round})
+	if err := unstructured.SetNestedField(snapshot.Object, claim, "spec", "source", "persistentVolumeClaimName"); err != nil {
+		return err
+	}
+	if len(policy.VolumeSnapshotClassName) != 0 {
+		if err := unstructured.SetNestedField(snapshot.Object, policy.VolumeSnapshotClassName, "spec", "volumeSnapshotClassName"); err != nil {
+			return err
+		}
+	}
+	return r.Create(ctx, snapshot)
+}
+
+// endSnapshotRound deletes the snapshots of a failed round and removes the round from the statefulset, it returns cause.
+func endSnapshotRound(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet, snapshots []unstructured.Unstructured, cause error) error {
+	for idx := range snapshots {
+		if err := r.Delete(ctx, &snapshots[idx]); err != nil && !errors.IsNotFound(err) {
+			log.Log.Error(err, fmt.Sprintf("failed to delete snapshot %s/%s.", snapshots[idx].GetNamespace(), snapshots[idx].GetName()))
+		}
+	}
+	if err := clearSnapshotRound(ctx, r, statefulSet); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to end snapshot round of statefulset %s/%s.", statefulSet.Namespace, statefulSet.Name))
+	}
+	return cause
+}
+
+// clearSnapshotRound removes the snapshot round from the statefulset, the next scale down to 0 takes new snapshots.
+func clearSnapshotRound(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet) error {
+	if _, ok := statefulSet.Annotations[util.SnapshotRoundAnnotation]; !ok {
+		return nil
+	}
+	patch := client.MergeFrom(statefulSet.DeepCopy())
+	delete(statefulSet.Annotations, util.SnapshotRoundAnnotation)
+	return r.Patch(ctx, statefulSet, patch)
+}
+
+// pruneVolumeSnapshots deletes the snapshots taken by the operator for the statefulset beyond the retention of the policy,
+// oldest rounds first, per persistentvolumeclaim.
+func pruneVolumeSnapshots(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet, policy workloadschedulerv1.VolumeSnapshotPolicy) {
+	retention := int(policy.Retention)
+	if retention < 1 {
+		retention = defaultSnapshotRetention
+	}
+	snapshots, err := listVolumeSnapshots(ctx, r, statefulSet, client.MatchingLabels{util.SnapshotStatefulSetLabel: statefulSet.Name})
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to list snapshots of statefulset %s/%s.", statefulSet.Namespace, statefulSet.Name))
+		return
+	}
+	claimSnapshots := map[string][]*unstructured.Unstructured{}
+	for idx := range snapshots {
+		claim, _, _ := unstructured.NestedString(snapshots[idx].Object, "spec", "source", "persistentVolumeClaimName")
+		claimSnapshots[claim] = append(claimSnapshots[claim], &snapshots[idx])
+	}
+	for _, _snapshots := range claimSnapshots {
+		// rounds are timestamps, sorting them lexically sorts them from the newest.
+		sort.Slice(_snapshots, func(i, j int) bool {
+			return _snapshots[i].GetLabels()[util.SnapshotRoundLabel] > _snapshots[j].GetLabels()[util.SnapshotRoundLabel]
+		})
+		for idx := retention; idx < len(_snapshots); idx++ {
+			if err = r.Delete(ctx, _snapshots[idx]); err != nil && !errors.IsNotFound(err) {
+				log.Log.Error(err, fmt.Sprintf("failed to delete snapshot %s/%s.", _snapshots[idx].GetNamespace(), _snapshots[idx].GetName()))
+			} else {
+				log.Log.Info(fmt.Sprintf("deleted snapshot NS: %v, Name: %v", _snapshots[idx].GetNamespace(), _snapshots[idx].GetName()))
+			}
+		}
+	}
+}
+
+func listVolumeSnapshots(ctx context.Context, r client.Client, statefulSet *apps.StatefulSet, labels client.MatchingLabels) ([]unstructured.Unstructured, error) {
+	var snapshots unstructured.UnstructuredList
+	snapshots.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind(volumeSnapshotGVK.Kind + "List"))
+	if err := r.List(ctx, &snapshots, client.InNamespace(statefulSet.Namespace), labels); err != nil {
+		return nil, err
+	}
+	return snapshots.Items, nil
+}
+
+// awaitScaleDown returns true while the workload is prepared to be scaled to 0 e.g. the snapshots of the volumes of a
+// statefulset are taken, the preparation of a scale down that did not happen is cancelled so a later one starts over.
+func (w *WorkloadScheduleHandler) awaitScaleDown(r client.Client, ctx context.Context, pass *actionPass, change *workloadChange) bool {
+	preparer, ok := change.handler.(ScaleDownPreparer)
+	if !ok {
+		return false
+	}
+	workload := change.workload
+	if change.desired != 0 || change.current == 0 {
+		if err := preparer.CancelScaleDown(ctx, r, workload); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to cancel scale down of %s. Namespace: %s, Name: %s", change.kind, workload.GetNamespace(), workload.GetName()))
+		}
+		return false
+	}
+	ready, err := preparer.PrepareScaleDown(ctx, r, workload, change.workloadSchedule)
+	if err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to prepare %s for workloadschedule %s, not scaling it to 0. Namespace: %s, Name: %s", change.kind, change.workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+		w.getStatus(change.workloadSchedule, pass.statuses).WorkloadErrors = append(w.getStatus(change.workloadSchedule, pass.statuses).WorkloadErrors, fmt.Sprintf("%s/%s/%s: %v", workload.GetNamespace(), change.kind, workload.GetName(), err))
+	} else if !ready {
+		log.Log.Info(fmt.Sprintf("%v waiting for NS: %v, Name: %v to be prepared before scaling it to 0", change.workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName()))
+	}
+	return !ready
+}
@@ -0,0 +1,153 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestWorkloadScheduleHandler_snapshotVolumes(t *testing.T) {
+	newSnapshot := func(claim string, round string) *unstructured.Unstructured {
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		snapshot.SetNamespace("dev")
+		snapshot.SetName(claim + "-" + round)
+		snapshot.SetLabels(map[string]string{util.SnapshotStatefulSetLabel: "db", util.SnapshotRoundLabel: round})
+		_ = unstructured.SetNestedField(snapshot.Object, claim, "spec", "source", "persistentVolumeClaimName")
+		_ = unstructured.SetNestedField(snapshot.Object, true, "status", "readyToUse")
+		return snapshot
+	}
+	tests := []struct {
+		name          string
+		status        map[string]interface{}
+		wantReplicas  int32
+		wantSnapshots int
+		wantErrors    int
+	}{
+		{name: "should scale to 0 once snapshots are ready and prune snapshots beyond retention.", status: map[string]interface{}{"readyToUse": true}, wantSnapshots: 4},
+		{name: "should not scale to 0 while snapshots are not ready.", status: map[string]interface{}{"readyToUse": false}, wantReplicas: 2, wantSnapshots: 5},
+		{name: "should not scale to 0 when a snapshot failed.", status: map[string]interface{}{"readyToUse": false, "error": map[string]interface{}{"message": "driver failed"}},
+			wantReplicas: 2, wantSnapshots: 3, wantErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statefulSet := &apps.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "db"}, Spec: apps.StatefulSetSpec{Replicas: pointer.Int32(2),
+				VolumeClaimTemplates: []core.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}}}
+			c := newTestClient(statefulSet,
+				&core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "data-db-0"}},
+				&core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "data-db-1"}},
+				newSnapshot("data-db-0", "20230601030000"), newSnapshot("data-db-0", "20230602030000"), newSnapshot("data-db-1", "20230602030000"))
+			w := New()
+			workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.STATEFULSET, Name: "db", Desired: 0, Snapshot: &v1.VolumeSnapshotPolicy{Retention: 2}}}
+
+			// the first run takes the snapshots, the second one waits for them.
+			if _, err := w.executeAction(workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(statefulSet), statefulSet); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *statefulSet.Spec.Replicas != 2 {
+				t.Fatalf("executeAction() replicas = %v, want 2 before snapshots are taken", *statefulSet.Spec.Replicas)
+			}
+			round := statefulSet.Annotations[util.SnapshotRoundAnnotation]
+			for _, claim := range []string{"data-db-0", "data-db-1"} {
+				snapshot := newSnapshot(claim, round)
+				if err := c.Get(context.Background(), client.ObjectKeyFromObject(snapshot), snapshot); err != nil {
+					t.Fatalf("Get() snapshot of %s error = %v", claim, err)
+				}
+				snapshot.Object["status"] = tt.status
+				if err := c.Update(context.Background(), snapshot); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+
+			statuses, err := w.executeAction(workloadSchedules, c, context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			if err = c.Get(context.Background(), client.ObjectKeyFromObject(statefulSet), statefulSet); err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if *statefulSet.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("executeAction() replicas = %v, want %v", *statefulSet.Spec.Replicas, tt.wantReplicas)
+			}
+			snapshots, err := listVolumeSnapshots(context.Background(), c, statefulSet, client.MatchingLabels{util.SnapshotStatefulSetLabel: "db"})
+			if err != nil {
+				t.Fatalf("listVolumeSnapshots() error = %v", err)
+			}
+			if len(snapshots) != tt.wantSnapshots {
+				t.Errorf("executeAction() snapshots = %v, want %v", len(snapshots), tt.wantSnapshots)
+			}
+			if got := len(w.getStatus(workloadSchedules[0], statuses).WorkloadErrors); got != tt.wantErrors {
+				t.Errorf("executeAction() workload errors = %v, want %v", got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_awaitSnapshots_deferred(t *testing.T) {
+	t.Setenv(config.MaxChangesPerRun, "1")
+	statefulSet := &apps.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "db"}, Spec: apps.StatefulSetSpec{Replicas: pointer.Int32(2),
+		VolumeClaimTemplates: []core.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}}}
+	c := newTestClient(statefulSet, newTestDeployment("dev", "api", 2, nil),
+		&core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "data-db-0"}})
+	w := New()
+	_, err := w.executeAction([]v1.WorkloadScheduleData{
+		{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 0},
+		{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.STATEFULSET, Name: "db", Desired: 0, Snapshot: &v1.VolumeSnapshotPolicy{}},
+	}, c, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	if err = c.Get(context.Background(), client.ObjectKeyFromObject(statefulSet), statefulSet); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if round, ok := statefulSet.Annotations[util.SnapshotRoundAnnotation]; ok {
+		t.Errorf("executeAction() snapshot round = %v, want none while the change is deferred", round)
+	}
+	snapshots, err := listVolumeSnapshots(context.Background(), c, statefulSet, client.MatchingLabels{util.SnapshotStatefulSetLabel: "db"})
+	if err != nil {
+		t.Fatalf("listVolumeSnapshots() error = %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("executeAction() snapshots = %v, want none while the change is deferred", len(snapshots))
+	}
+}
+
+func Test_getPersistentVolumeClaims(t *testing.T) {
+	tests := []struct {
+		name       string
+		ordinals   *apps.StatefulSetOrdinals
+		wantClaims []string
+	}{
+		{name: "should number claims from 0.", wantClaims: []string{"data-db-0", "data-db-1"}},
+		{name: "should number claims from the start ordinal.", ordinals: &apps.StatefulSetOrdinals{Start: 3}, wantClaims: []string{"data-db-3", "data-db-4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statefulSet := &apps.StatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "db"}, Spec: apps.StatefulSetSpec{Replicas: pointer.Int32(2), Ordinals: tt.ordinals,
+				VolumeClaimTemplates: []core.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}}}
+			var objs []client.Object
+			for _, name := range []string{"data-db-0", "data-db-1", "data-db-3", "data-db-4"} {
+				objs = append(objs, &core.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: name}})
+			}
+			claims, err := getPersistentVolumeClaims(context.Background(), newTestClient(objs...), statefulSet)
+			if err != nil {
+				t.Fatalf("getPersistentVolumeClaims() error = %v", err)
+			}
+			if !reflect.DeepEqual(claims, tt.wantClaims) {
+				t.Errorf("getPersistentVolumeClaims() got = %v, want %v", claims, tt.wantClaims)
+			}
+		})
+	}
+}
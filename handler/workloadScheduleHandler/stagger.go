@@ -0,0 +1,207 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+	"time"
+)
+
+// deferChange returns why the change of the workload is deferred to a following run, empty when it is rolled out in this run.
+// Workloads wait for their offset within JITTER_WINDOW from the start of the schedule, then at most MAX_CHANGES_PER_RUN
+// workloads and NAMESPACE_BATCH_SIZE workloads per namespace are changed per run, in the order they are evaluated.
+// A workload already changed in this run is not deferred, it only counts once.
+func (w *WorkloadScheduleHandler) deferChange(pass *actionPass, workload client.Object, processedWorkloadKey string, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) string {
+	if _, ok := pass.changedWorkloads[processedWorkloadKey]; ok {
+		return ""
+	}
+	if window := w.getRolloutLimit(config.JitterWindow); window > 0 && _workloadSchedule.StartTime != nil {
+		offset := getJitterOffset(processedWorkloadKey, time.Duration(window)*time.Second)
+		if since := time.Since(_workloadSchedule.StartTime.Time); since < offset {
+			return fmt.Sprintf("jitter of %s, %s left", offset, (offset - since).Round(time.Second))
+		}
+	}
+	if maxChanges := w.getRolloutLimit(config.MaxChangesPerRun); maxChanges > 0 && pass.changes >= maxChanges {
+		return fmt.Sprintf("%d workloads changed in this run", pass.changes)
+	}
+	if batchSize := w.getRolloutLimit(config.NamespaceBatchSize); batchSize > 0 && pass.namespaceChanges[workload.GetNamespace()] >= batchSize {
+		return fmt.Sprintf("%d workloads of namespace %s changed in this run", pass.namespaceChanges[workload.GetNamespace()], workload.GetNamespace())
+	}
+	return ""
+}
+
+// isDeferred returns true when the change of the workload is deferred to a following run, the deferral is logged and
+// listed in the status of the workload schedule.
+func (w *WorkloadScheduleHandler) isDeferred(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, pass *actionPass, workload client.Object, processedWorkloadKey string, change string) bool {
+	deferred := w.deferChange(pass, workload, processedWorkloadKey, _workloadSchedule)
+	if len(deferred) == 0 {
+		return false
+	}
+	log.Log.Info(fmt.Sprintf("%v deferred %s of NS: %v, Name: %v, %s", _workloadSchedule.WorkloadScheduler, change, workload.GetNamespace(), workload.GetName(), deferred))
+	w.getStatus(_workloadSchedule, pass.statuses).DeferredWorkloads = append(w.getStatus(_workloadSchedule, pass.statuses).DeferredWorkloads, fmt.Sprintf("%s: %s", processedWorkloadKey, deferred))
+	return true
+}
+
+// isRestoreDeferred returns true when the restore of an object no workload schedule applies to anymore is deferred to a
+// following run, restores have no schedule so they only wait for the limits of changes per run.
+func (w *WorkloadScheduleHandler) isRestoreDeferred(pass *actionPass, object client.Object, processedWorkloadKey string) bool {
+	deferred := w.deferChange(pass, object, processedWorkloadKey, workloadschedulerv1.WorkloadScheduleData{})
+	if len(deferred) == 0 {
+		return false
+	}
+	log.Log.Info(fmt.Sprintf("deferred restore of %s, %s", processedWorkloadKey, deferred))
+	return true
+}
+
+// countChange counts the workload as changed in this run once a write changing it succeeded.
+func (p *actionPass) countChange(workload client.Object, processedWorkloadKey string) {
+	if _, ok := p.changedWorkloads[processedWorkloadKey]; ok {
+		return
+	}
+	p.changedWorkloads[processedWorkloadKey] = struct{}{}
+	p.changes++
+	p.namespaceChanges[workload.GetNamespace()]++
+}
+
+// changeCountingClient counts the objects it writes successfully as changed in this run, it is passed to the handlers
+// restoring workloads.
+type changeCountingClient struct {
+	client.Client
+	pass *actionPass
+	kind string
+}
+
+func (c *changeCountingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.pass.countChange(obj, fmt.Sprintf("%s/%s/%s", obj.GetNamespace(), c.kind, obj.GetName()))
+	return nil
+}
+
+func (c *changeCountingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	c.pass.countChange(obj, fmt.Sprintf("%s/%s/%s", obj.GetNamespace(), c.kind, obj.GetName()))
+	return nil
+}
+
+// getRolloutLimit returns the value of the env, 0 (no limit) when it is not set or not valid.
+func (w *WorkloadScheduleHandler) getRolloutLimit(env string) int {
+	if _, ok := w.Config.Provider.LookUpEnv(env); !ok {
+		return 0
+	}
+	limit, err := w.Config.LookUpIntEnv(env)
+	if err != nil || limit < 0 {
+		log.Log.Error(fmt.Errorf("invalid %s env, expected a positive number", env), "ignoring rollout limit")
+		return 0
+	}
+	return limit
+}
+
+// getJitterOffset returns the offset of the workload within window, derived from its key so it is the same on every run.
+func getJitterOffset(processedWorkloadKey string, window time.Duration) time.Duration {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(processedWorkloadKey))
+	return time.Duration(hash.Sum32()%uint32(window/time.Second)) * time.Second
+}
+
+// limitWrites returns r with its writes capped to WRITE_QPS writes per second, r when it is not set.
+func (w *WorkloadScheduleHandler) limitWrites(r client.Client) client.Client {
+	value, ok := w.Config.Provider.LookUpEnv(config.WriteQPS)
+	if !ok {
+		return r
+	}
+	qps, err := strconv.ParseFloat(value, 32)
+	if err != nil || qps <= 0 {
+		log.Log.Error(fmt.Errorf("invalid %s env, expected a positive number", config.WriteQPS), "ignoring write limit")
+		return r
+	}
+	if w.writeLimiter == nil || w.writeLimiter.QPS() != float32(qps) {
+		w.writeLimiter = flowcontrol.NewTokenBucketRateLimiter(float32(qps), 1)
+	}
+	return &writeLimitedClient{Client: r, limiter: w.writeLimiter}
+}
+
+// writeLimitedClient waits for the limiter before every write.
+type writeLimitedClient struct {
+	client.Client
+	limiter flowcontrol.RateLimiter
+}
+
+func (c *writeLimitedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *writeLimitedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *writeLimitedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *writeLimitedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *writeLimitedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *writeLimitedClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *writeLimitedClient) SubResource(subResource string) client.SubResourceClient {
+	return &writeLimitedSubResourceClient{SubResourceClient: c.Client.SubResource(subResource), limiter: c.limiter}
+}
+
+// writeLimitedSubResourceClient waits for the limiter before every write of a subresource e.g. scale or status.
+type writeLimitedSubResourceClient struct {
+	client.SubResourceClient
+	limiter flowcontrol.RateLimiter
+}
+
+func (c *writeLimitedSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (c *writeLimitedSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.SubResourceClient.Update(ctx, obj, opts...)
+}
+
+func (c *writeLimitedSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,150 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"fmt"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+func TestWorkloadScheduleHandler_deferChange(t *testing.T) {
+	justStarted := &metav1.Time{Time: time.Now()}
+	longStarted := &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	tests := []struct {
+		name         string
+		envs         map[string]string
+		startTime    *metav1.Time
+		wantChanged  []int
+		wantDeferred int
+	}{
+		{name: "should change every workload without limits.", wantChanged: []int{3, 1}},
+		{name: "should change at most max changes per run.", envs: map[string]string{config.MaxChangesPerRun: "2"}, wantChanged: []int{2, 0}, wantDeferred: 2},
+		{name: "should change at most the batch size per namespace.", envs: map[string]string{config.NamespaceBatchSize: "2"}, wantChanged: []int{2, 1}, wantDeferred: 1},
+		{name: "should defer workloads within their jitter offset.", envs: map[string]string{config.JitterWindow: "86400"}, startTime: justStarted, wantChanged: []int{0, 0}, wantDeferred: 4},
+		{name: "should change workloads past the jitter window.", envs: map[string]string{config.JitterWindow: "60"}, startTime: longStarted, wantChanged: []int{3, 1}},
+		{name: "should not limit writes to the api server.", envs: map[string]string{config.WriteQPS: "1000"}, wantChanged: []int{3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for env, value := range tt.envs {
+				t.Setenv(env, value)
+			}
+			var objs []client.Object
+			for _, name := range []string{"a", "b", "c"} {
+				objs = append(objs, newTestDeployment("dev", name, 1, nil))
+			}
+			objs = append(objs, newTestDeployment("prod", "a", 1, nil))
+			c := newTestClient(objs...)
+			w := New()
+			workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "*", Kind: util.DEPLOYMENT, Name: "*", Desired: 0, StartTime: tt.startTime}}
+
+			statuses, err := w.executeAction(workloadSchedules, w.limitWrites(c), context.Background())
+			if err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			var changed [2]int
+			for idx, namespace := range []string{"dev", "prod"} {
+				var deployments apps.DeploymentList
+				if err = c.List(context.Background(), &deployments, client.InNamespace(namespace)); err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				for _, deployment := range deployments.Items {
+					if *deployment.Spec.Replicas == 0 {
+						changed[idx]++
+					}
+				}
+			}
+			if changed[0] != tt.wantChanged[0] || changed[1] != tt.wantChanged[1] {
+				t.Errorf("executeAction() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if got := len(w.getStatus(workloadSchedules[0], statuses).DeferredWorkloads); got != tt.wantDeferred {
+				t.Errorf("executeAction() deferred = %v, want %v", got, tt.wantDeferred)
+			}
+		})
+	}
+}
+
+// failingUpdateClient fails the updates of the workloads named name.
+type failingUpdateClient struct {
+	client.Client
+	name string
+}
+
+func (c *failingUpdateClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if obj.GetName() == c.name {
+		return fmt.Errorf("update failed")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestWorkloadScheduleHandler_deferChange_countsSuccessfulChanges(t *testing.T) {
+	t.Setenv(config.MaxChangesPerRun, "1")
+	c := newTestClient(newTestDeployment("dev", "a", 1, nil), newTestDeployment("dev", "b", 1, nil))
+	w := New()
+	workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "*", Desired: 0}}
+
+	statuses, err := w.executeAction(workloadSchedules, &failingUpdateClient{Client: c, name: "a"}, context.Background())
+	if err != nil {
+		t.Fatalf("executeAction() error = %v", err)
+	}
+	for name, want := range map[string]int32{"a": 1, "b": 0} {
+		deployment := &apps.Deployment{}
+		if err = c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: name}, deployment); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if *deployment.Spec.Replicas != want {
+			t.Errorf("executeAction() replicas of %s = %v, want %v", name, *deployment.Spec.Replicas, want)
+		}
+	}
+	if got := w.getStatus(workloadSchedules[0], statuses).DeferredWorkloads; len(got) != 0 {
+		t.Errorf("executeAction() deferred = %v, want none", got)
+	}
+}
+
+func TestWorkloadScheduleHandler_deferChange_restores(t *testing.T) {
+	t.Setenv(config.RestoreBaseline, "true")
+	t.Setenv(config.MaxChangesPerRun, "2")
+	var objs []client.Object
+	for _, name := range []string{"a", "b", "c"} {
+		deployment := newTestDeployment("dev", name, 0, nil)
+		deployment.Annotations = map[string]string{util.BaselineReplicasAnnotation: "1"}
+		objs = append(objs, deployment)
+	}
+	c := newTestClient(objs...)
+	w := New()
+	w.trackKind(baselineKindsKey, util.DEPLOYMENT)
+	for _, wantRestored := range []int{2, 3} {
+		if _, err := w.executeAction(nil, c, context.Background()); err != nil {
+			t.Fatalf("executeAction() error = %v", err)
+		}
+		var deployments apps.DeploymentList
+		if err := c.List(context.Background(), &deployments); err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		restored := 0
+		for _, deployment := range deployments.Items {
+			if *deployment.Spec.Replicas == 1 {
+				restored++
+			}
+		}
+		if restored != wantRestored {
+			t.Errorf("executeAction() restored = %v, want %v", restored, wantRestored)
+		}
+	}
+}
+
+func Test_getJitterOffset(t *testing.T) {
+	offset := getJitterOffset("dev/deployment/api", 10*time.Minute)
+	if offset < 0 || offset >= 10*time.Minute {
+		t.Errorf("getJitterOffset() = %v, want within 10m", offset)
+	}
+	if again := getJitterOffset("dev/deployment/api", 10*time.Minute); again != offset {
+		t.Errorf("getJitterOffset() = %v, want the same offset %v on every run", again, offset)
+	}
+}
@@ -0,0 +1,67 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"context"
+	"fmt"
+	apps "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// StatefulSetHandler adjusts spec.replicas of statefulsets.
+type StatefulSetHandler struct {
+	DesiredComputer
+}
+
+func NewStatefulSetHandler() *StatefulSetHandler {
+	return &StatefulSetHandler{}
+}
+
+func (s *StatefulSetHandler) NewWorkload() client.Object {
+	return &apps.StatefulSet{}
+}
+
+func (s *StatefulSetHandler) ListWorkloads(ctx context.Context, r client.Client, opts ...client.ListOption) ([]client.Object, error) {
+	var statefulSetWorkloads apps.StatefulSetList
+	if err := r.List(ctx, &statefulSetWorkloads, opts...); err != nil {
+		return nil, err
+	}
+	var workloads []client.Object
+	for idx := range statefulSetWorkloads.Items {
+		workloads = append(workloads, &statefulSetWorkloads.Items[idx])
+	}
+	return workloads, nil
+}
+
+func (s *StatefulSetHandler) GetCurrent(_ context.Context, _ client.Client, workload client.Object) (int32, error) {
+	statefulSet := workload.(*apps.StatefulSet)
+	if statefulSet.Spec.Replicas == nil {
+		return 1, nil
+	}
+	return *statefulSet.Spec.Replicas, nil
+}
+
+func (s *StatefulSetHandler) Apply(ctx context.Context, r client.Client, workload client.Object, desired int32) error {
+	statefulSet := workload.(*apps.StatefulSet)
+	statefulSet.Spec.Replicas = &desired
+	return r.Update(ctx, statefulSet)
+}
+
+// PrepareScaleDown takes the snapshots of the volumes of the statefulset when the workload schedule has a snapshot policy,
+// it returns true once they are ready to use.
+func (s *StatefulSetHandler) PrepareScaleDown(ctx context.Context, r client.Client, workload client.Object, _workloadSchedule workloadschedulerv1.WorkloadScheduleData) (bool, error) {
+	statefulSet := workload.(*apps.StatefulSet)
+	if _workloadSchedule.Snapshot == nil {
+		if err := clearSnapshotRound(ctx, r, statefulSet); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to clear snapshot round of statefulset. Namespace: %s, Name: %s", statefulSet.Namespace, statefulSet.Name))
+		}
+		return true, nil
+	}
+	return snapshotVolumes(ctx, r, statefulSet, *_workloadSchedule.Snapshot)
+}
+
+// CancelScaleDown clears the snapshot round of the statefulset so a later scale down takes new snapshots.
+func (s *StatefulSetHandler) CancelScaleDown(ctx context.Context, r client.Client, workload client.Object) error {
+	return clearSnapshotRound(ctx, r, workload.(*apps.StatefulSet))
+}
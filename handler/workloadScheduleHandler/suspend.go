@@ -0,0 +1,40 @@
+package workloadScheduleHandler
+
+import (
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func suspendToReplicas(suspend *bool) int32 {
+	if suspend != nil && *suspend {
+		return 0
+	}
+	return 1
+}
+
+// suspendDesired maps desired replicas to the suspend state, workloads suspended by their owners are left suspended.
+func suspendDesired(workload client.Object, current int32, desired int32) int32 {
+	if desired == 0 {
+		return 0
+	}
+	if _, ok := workload.GetAnnotations()[util.SuspendedAnnotation]; current == 0 && !ok {
+		return 0
+	}
+	return 1
+}
+
+// applySuspend records whether the operator suspended the workload and returns the suspend value to set.
+func applySuspend(workload client.Object, desired int32) *bool {
+	annotations := workload.GetAnnotations()
+	suspend := desired == 0
+	if suspend {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[util.SuspendedAnnotation] = "true"
+	} else {
+		delete(annotations, util.SuspendedAnnotation)
+	}
+	workload.SetAnnotations(annotations)
+	return &suspend
+}
@@ -0,0 +1,193 @@
+package workloadScheduleHandler
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"context"
+	"encoding/json"
+	"fmt"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
+	"time"
+)
+
+const (
+	// DegradedCondition is true while workloads scaled up by a workload schedule did not report ready replicas in time.
+	DegradedCondition = "Degraded"
+	// defaultVerificationTimeout is the time in seconds a scaled up workload has to be ready when verify sets none.
+	defaultVerificationTimeout = 300
+)
+
+// verification is the scale up of a workload verified for ready replicas.
+type verification struct {
+	WorkloadSchedule string      `json:"workloadSchedule"`
+	Previous         int32       `json:"previous"`
+	Desired          int32       `json:"desired"`
+	Deadline         metav1.Time `json:"deadline"`
+	// Failed is set once the workload was not ready in time, RolledBack once it was scaled back to Previous.
+	Failed     bool `json:"failed,omitempty"`
+	RolledBack bool `json:"rolledBack,omitempty"`
+}
+
+// startVerification records the scale up of the workload from previous to desired replicas, its ready replicas are
+// verified by following runs until the timeout of verify.
+func startVerification(ctx context.Context, r client.Client, _workloadSchedule workloadschedulerv1.WorkloadScheduleData, workload client.Object, previous int32, desired int32) error {
+	if _workloadSchedule.Verify == nil || desired <= previous || getPodTemplate(workload) == nil {
+		return nil
+	}
+	timeout := _workloadSchedule.Verify.TimeoutSeconds
+	if timeout < 1 {
+		timeout = defaultVerificationTimeout
+	}
+	return setVerification(ctx, r, workload, &verification{WorkloadSchedule: _workloadSchedule.WorkloadScheduler, Previous: previous, Desired: desired,
+		Deadline: metav1.NewTime(time.Now().Add(time.Duration(timeout) * time.Second).Truncate(time.Second))})
+}
+
+// verifyReadiness checks the ready replicas of a workload scaled up with verify, it returns the replicas the workload is
+// brought to and whether that rolls it back. A workload not ready in time gets a warning event and is listed in the
+// degraded workloads of the workload schedule, when verify has rollback it is brought back to and held at its replicas
+// before the scale up through the same path as any other change.
+func (w *WorkloadScheduleHandler) verifyReadiness(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, pass *actionPass, kind string, workload client.Object, current int32, desired int32) (int32, bool) {
+	processedWorkloadKey := fmt.Sprintf("%s/%s/%s", workload.GetNamespace(), kind, workload.GetName())
+	_verification, err := getVerification(workload)
+	if _verification == nil {
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("invalid verification of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+			w.clearVerification(ctx, r, kind, workload)
+		}
+		return desired, false
+	}
+	// the verification ends once the workload is scheduled to other replicas or without verify.
+	if _workloadSchedule.Verify == nil || _verification.Desired != desired {
+		w.clearVerification(ctx, r, kind, workload)
+		return desired, false
+	}
+	degraded := func(reason string) {
+		w.getStatus(_workloadSchedule, pass.statuses).DegradedWorkloads = append(w.getStatus(_workloadSchedule, pass.statuses).DegradedWorkloads, fmt.Sprintf("%s: %s", processedWorkloadKey, reason))
+	}
+	if _verification.RolledBack {
+		degraded(fmt.Sprintf("rolled back to %d replicas, not ready at %d replicas by %s", _verification.Previous, desired, _verification.Deadline.Format(time.RFC3339)))
+		return _verification.Previous, false
+	}
+	if !_verification.Failed && current != desired {
+		return desired, false
+	}
+
+	if !_verification.Failed {
+		readyReplicas, err := getReadyReplicas(workload)
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to get ready replicas of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+			return desired, false
+		}
+		if readyReplicas >= desired {
+			log.Log.Info(fmt.Sprintf("%v verified NS: %v, Name: %v, %d/%d ready", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), readyReplicas, desired))
+			w.clearVerification(ctx, r, kind, workload)
+			return desired, false
+		}
+		if time.Now().Before(_verification.Deadline.Time) {
+			return desired, false
+		}
+		_verification.Failed = true
+		w.recordEvent(workload, "ReadinessTimeout", fmt.Sprintf("%s scaled up by workloadschedule %s not ready in time, %d/%d ready by %s", kind, _workloadSchedule.WorkloadScheduler, readyReplicas, desired, _verification.Deadline.Format(time.RFC3339)))
+		if err = setVerification(ctx, r, workload, _verification); err != nil {
+			log.Log.Error(err, fmt.Sprintf("failed to record verification of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+		}
+	}
+	degraded(fmt.Sprintf("not ready at %d replicas by %s", desired, _verification.Deadline.Format(time.RFC3339)))
+	if !_workloadSchedule.Verify.Rollback {
+		return desired, false
+	}
+	log.Log.Info(fmt.Sprintf("%v rolling back NS: %v, Name: %v, from %v to %v", _workloadSchedule.WorkloadScheduler, workload.GetNamespace(), workload.GetName(), desired, _verification.Previous))
+	return _verification.Previous, true
+}
+
+// completeRollback records the roll back of the workload to the replicas before its scale up, so it is held at them.
+func (w *WorkloadScheduleHandler) completeRollback(_workloadSchedule workloadschedulerv1.WorkloadScheduleData, r client.Client, ctx context.Context, kind string, workload client.Object, desired int32) {
+	_verification, err := getVerification(workload)
+	if _verification == nil {
+		if err != nil {
+			log.Log.Error(err, fmt.Sprintf("invalid verification of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+		}
+		return
+	}
+	_verification.RolledBack = true
+	if err = setVerification(ctx, r, workload, _verification); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to record verification of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+	}
+	w.recordEvent(workload, "RolledBack", fmt.Sprintf("%s rolled back from %d to %d replicas by workloadschedule %s", kind, _verification.Desired, desired, _workloadSchedule.WorkloadScheduler))
+}
+
+func (w *WorkloadScheduleHandler) recordEvent(workload client.Object, reason string, message string) {
+	if w.Recorder == nil {
+		return
+	}
+	w.Recorder.Event(workload, core.EventTypeWarning, reason, message)
+}
+
+func (w *WorkloadScheduleHandler) clearVerification(ctx context.Context, r client.Client, kind string, workload client.Object) {
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	annotations := workload.GetAnnotations()
+	delete(annotations, util.VerificationAnnotation)
+	workload.SetAnnotations(annotations)
+	if err := r.Patch(ctx, workload, patch); err != nil {
+		log.Log.Error(err, fmt.Sprintf("failed to clear verification of %s. Namespace: %s, Name: %s", kind, workload.GetNamespace(), workload.GetName()))
+	}
+}
+
+func setVerification(ctx context.Context, r client.Client, workload client.Object, _verification *verification) error {
+	record, err := json.Marshal(_verification)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+	setAnnotation(workload, util.VerificationAnnotation, string(record))
+	return r.Patch(ctx, workload, patch)
+}
+
+func getVerification(workload client.Object) (*verification, error) {
+	record, ok := workload.GetAnnotations()[util.VerificationAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var _verification verification
+	if err := json.Unmarshal([]byte(record), &_verification); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation. %v", util.VerificationAnnotation, err)
+	}
+	return &_verification, nil
+}
+
+func getReadyReplicas(workload client.Object) (int32, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(workload)
+	if err != nil {
+		return 0, err
+	}
+	readyReplicas, _, err := unstructured.NestedInt64(object, "status", "readyReplicas")
+	return int32(readyReplicas), err
+}
+
+// getConditions returns the conditions of the workload schedule with its Degraded condition for degraded workloads, the
+// condition is only set on workload schedules verifying scale ups or holding it already.
+func getConditions(workloadSchedule workloadschedulerv1.WorkloadSchedule, degradedWorkloads []string) []metav1.Condition {
+	conditions := append([]metav1.Condition(nil), workloadSchedule.Status.Conditions...)
+	verifies := meta.FindStatusCondition(conditions, DegradedCondition) != nil
+	for _, unit := range workloadSchedule.Spec.Schedules {
+		verifies = verifies || unit.Verify != nil
+	}
+	if !verifies {
+		return conditions
+	}
+	condition := metav1.Condition{Type: DegradedCondition, Status: metav1.ConditionFalse, Reason: "WorkloadsReady", Message: "scaled up workloads are ready",
+		ObservedGeneration: workloadSchedule.Generation}
+	if len(degradedWorkloads) != 0 {
+		condition.Status, condition.Reason = metav1.ConditionTrue, "ReadinessTimeout"
+		condition.Message = fmt.Sprintf("scaled up workloads not ready in time: %s", strings.Join(degradedWorkloads, ", "))
+	}
+	meta.SetStatusCondition(&conditions, condition)
+	return conditions
+}
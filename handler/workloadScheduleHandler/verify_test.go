@@ -0,0 +1,162 @@
+package workloadScheduleHandler
+
+import (
+	v1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/util"
+	"bennsimon.github.io/workload-scheduler-operator/util/config"
+	"context"
+	"encoding/json"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+func TestWorkloadScheduleHandler_verifyReadiness(t *testing.T) {
+	tests := []struct {
+		name   string
+		verify v1.ReadinessVerification
+		// ready replicas and whether the deadline passed before the second run.
+		readyReplicas int32
+		expired       bool
+		wantReplicas  []int32
+		wantDegraded  int
+		wantEvents    int
+		wantVerifying bool
+	}{
+		{name: "should end verification once replicas are ready.", verify: v1.ReadinessVerification{TimeoutSeconds: 60}, readyReplicas: 2,
+			wantReplicas: []int32{2, 2}},
+		{name: "should keep verifying replicas within the timeout.", verify: v1.ReadinessVerification{TimeoutSeconds: 60},
+			wantReplicas: []int32{2, 2}, wantVerifying: true},
+		{name: "should mark degraded replicas not ready by the deadline.", verify: v1.ReadinessVerification{TimeoutSeconds: 60}, readyReplicas: 1, expired: true,
+			wantReplicas: []int32{2, 2}, wantDegraded: 1, wantEvents: 1, wantVerifying: true},
+		{name: "should roll back replicas not ready by the deadline and hold them.", verify: v1.ReadinessVerification{TimeoutSeconds: 60, Rollback: true}, expired: true,
+			wantReplicas: []int32{0, 0}, wantDegraded: 1, wantEvents: 2, wantVerifying: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(newTestDeployment("dev", "api", 0, nil))
+			recorder := record.NewFakeRecorder(10)
+			w := New()
+			w.Recorder = recorder
+			workloadSchedules := []v1.WorkloadScheduleData{{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 2, Verify: &tt.verify}}
+			getDeployment := func() *apps.Deployment {
+				deployment := &apps.Deployment{}
+				if err := c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: "api"}, deployment); err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				return deployment
+			}
+
+			if _, err := w.executeAction(workloadSchedules, c, context.Background()); err != nil {
+				t.Fatalf("executeAction() error = %v", err)
+			}
+			deployment := getDeployment()
+			_verification, err := getVerification(deployment)
+			if err != nil || _verification == nil || _verification.Previous != 0 || _verification.Desired != 2 {
+				t.Fatalf("executeAction() verification = %+v, %v, want from 0 to 2", _verification, err)
+			}
+			if tt.expired {
+				_verification.Deadline = metav1.NewTime(time.Now().Add(-time.Minute))
+				value, _ := json.Marshal(_verification)
+				deployment.Annotations[util.VerificationAnnotation] = string(value)
+				if err = c.Update(context.Background(), deployment); err != nil {
+					t.Fatalf("Update() error = %v", err)
+				}
+			}
+			deployment.Status = apps.DeploymentStatus{Replicas: 2, ReadyReplicas: tt.readyReplicas}
+			if err = c.Status().Update(context.Background(), deployment); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			for run, want := range tt.wantReplicas {
+				statuses, err := w.executeAction(workloadSchedules, c, context.Background())
+				if err != nil {
+					t.Fatalf("executeAction() error = %v", err)
+				}
+				if got := *getDeployment().Spec.Replicas; got != want {
+					t.Errorf("executeAction() run %d replicas = %v, want %v", run, got, want)
+				}
+				if got := len(w.getStatus(workloadSchedules[0], statuses).DegradedWorkloads); got != tt.wantDegraded {
+					t.Errorf("executeAction() run %d degraded = %v, want %v", run, got, tt.wantDegraded)
+				}
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("executeAction() events = %v, want %v", len(recorder.Events), tt.wantEvents)
+			}
+			if _, ok := getDeployment().Annotations[util.VerificationAnnotation]; ok != tt.wantVerifying {
+				t.Errorf("executeAction() verifying = %v, want %v", ok, tt.wantVerifying)
+			}
+		})
+	}
+}
+
+func Test_getConditions(t *testing.T) {
+	verified := v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{Verify: &v1.ReadinessVerification{}}}}}
+	tests := []struct {
+		name             string
+		workloadSchedule v1.WorkloadSchedule
+		degraded         []string
+		wantStatus       metav1.ConditionStatus
+	}{
+		{name: "should not set the condition without verification.", workloadSchedule: v1.WorkloadSchedule{Spec: v1.WorkloadScheduleSpec{Schedules: []v1.WorkloadScheduleUnit{{}}}}},
+		{name: "should set the condition false when workloads are ready.", workloadSchedule: verified, wantStatus: metav1.ConditionFalse},
+		{name: "should set the condition true with degraded workloads.", workloadSchedule: verified, degraded: []string{"dev/deployment/api: 1/2 ready"}, wantStatus: metav1.ConditionTrue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions := getConditions(tt.workloadSchedule, tt.degraded)
+			if len(tt.wantStatus) == 0 {
+				if len(conditions) != 0 {
+					t.Errorf("getConditions() = %v, want none", conditions)
+				}
+				return
+			}
+			if len(conditions) != 1 || conditions[0].Type != DegradedCondition || conditions[0].Status != tt.wantStatus {
+				t.Errorf("getConditions() = %v, want %s %s", conditions, DegradedCondition, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWorkloadScheduleHandler_verifyReadiness_deferredRollback(t *testing.T) {
+	t.Setenv(config.MaxChangesPerRun, "1")
+	c := newTestClient(newTestDeployment("dev", "api", 2, nil), newTestDeployment("dev", "web", 2, nil))
+	w := New()
+	verify := &v1.ReadinessVerification{TimeoutSeconds: 60, Rollback: true}
+	deployment := &apps.Deployment{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: "api"}, deployment); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := setVerification(context.Background(), c, deployment, &verification{WorkloadSchedule: "ws", Previous: 0, Desired: 2, Deadline: metav1.NewTime(time.Now().Add(-time.Minute))}); err != nil {
+		t.Fatalf("setVerification() error = %v", err)
+	}
+
+	// web takes the only change of the first run, so the roll back of api waits for the next run.
+	runs := [][]v1.WorkloadScheduleData{
+		{{WorkloadScheduler: "other", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "web", Desired: 1},
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 2, Verify: verify}},
+		{{WorkloadScheduler: "other", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "web", Desired: 1},
+			{WorkloadScheduler: "ws", Namespace: "dev", Kind: util.DEPLOYMENT, Name: "api", Desired: 2, Verify: verify}},
+	}
+	for run, wantReplicas := range []int32{2, 0} {
+		statuses, err := w.executeAction(runs[run], c, context.Background())
+		if err != nil {
+			t.Fatalf("executeAction() error = %v", err)
+		}
+		if err = c.Get(context.Background(), client.ObjectKey{Namespace: "dev", Name: "api"}, deployment); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if *deployment.Spec.Replicas != wantReplicas {
+			t.Errorf("executeAction() run %d replicas = %v, want %v", run, *deployment.Spec.Replicas, wantReplicas)
+		}
+		if got := len(w.getStatus(runs[run][1], statuses).DeferredWorkloads); got != 1-run {
+			t.Errorf("executeAction() run %d deferred = %v, want %v", run, got, 1-run)
+		}
+	}
+	if _verification, _ := getVerification(deployment); _verification == nil || !_verification.RolledBack {
+		t.Errorf("executeAction() verification = %+v, want rolled back", _verification)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package controller
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -50,9 +51,16 @@ func TestControllers(t *testing.T) {
 var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
+	// envtest needs the binaries set up by make test, SKIP_ENVTEST=true skips the suite where they are not available.
+	if os.Getenv("SKIP_ENVTEST") == "true" {
+		Skip("SKIP_ENVTEST is set")
+	}
+
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		CRDDirectoryPaths: []string{filepath.Join("..", "..", "config", "crd", "bases"),
+			// third party CRDs the operator integrates with.
+			filepath.Join("testdata", "crds")},
 		ErrorIfCRDPathMissing: true,
 	}
 
@@ -74,6 +82,9 @@ var _ = BeforeSuite(func() {
 })
 
 var _ = AfterSuite(func() {
+	if testEnv == nil {
+		return
+	}
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
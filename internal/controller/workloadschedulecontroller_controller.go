@@ -21,7 +21,6 @@ import (
 	"bennsimon.github.io/workload-scheduler-operator/util/config"
 	"context"
 	"fmt"
-	apps "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"time"
@@ -42,8 +41,21 @@ type WorkloadScheduleControllerReconciler struct {
 	workloadScheduleHandler.IWorkloadScheduleHandler
 }
 
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;update;watch
-// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;update;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;patch;update;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;patch;update;watch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;patch;update;watch
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;patch;update;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;patch;update;watch
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;update;watch
+// +kubebuilder:rbac:groups=keda.sh,resources=scaledobjects,verbs=get;list;update;watch
+// +kubebuilder:rbac:groups=serving.knative.dev,resources=services,verbs=get;list;update;watch
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;update;watch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;update;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=create;delete;get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create
+// +kubebuilder:rbac:groups="",resources=configmaps,resourceNames=workload-scheduler-kinds,verbs=get;update
 
 func (r *WorkloadScheduleControllerReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	return reconcile.Result{}, nil
@@ -104,25 +116,15 @@ func (r *WorkloadScheduleControllerReconciler) RunJob(ctx context.Context) error
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkloadScheduleControllerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &apps.Deployment{}, config.IndexedField, func(rawObj client.Object) []string {
-		deployment := rawObj.(*apps.Deployment)
-
-		if deployment == nil {
-			return nil
-		}
-		return []string{deployment.ObjectMeta.Name}
-	}); err != nil {
-		return err
-	}
-	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &apps.StatefulSet{}, config.IndexedField, func(rawObj client.Object) []string {
-		statefulSet := rawObj.(*apps.StatefulSet)
-
-		if statefulSet == nil {
-			return nil
+	for _, workload := range r.IWorkloadScheduleHandler.IndexedWorkloads() {
+		if err := mgr.GetFieldIndexer().IndexField(context.TODO(), workload, config.IndexedField, func(rawObj client.Object) []string {
+			if rawObj == nil {
+				return nil
+			}
+			return []string{rawObj.GetName()}
+		}); err != nil {
+			return err
 		}
-		return []string{statefulSet.ObjectMeta.Name}
-	}); err != nil {
-		return err
 	}
 	return nil
 }
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	workloadschedulerv1 "bennsimon.github.io/workload-scheduler-operator/api/v1"
+	"bennsimon.github.io/workload-scheduler-operator/handler/workloadScheduleHandler"
+	"context"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("WorkloadScheduleControllerReconciler with KEDA", func() {
+	const namespace = "keda"
+	ctx := context.Background()
+	labels := map[string]string{"app": "consumer"}
+
+	newScaledObject := func() *unstructured.Unstructured {
+		scaledObject := &unstructured.Unstructured{}
+		scaledObject.SetAPIVersion("keda.sh/v1alpha1")
+		scaledObject.SetKind("ScaledObject")
+		scaledObject.SetNamespace(namespace)
+		scaledObject.SetName("consumer")
+		return scaledObject
+	}
+	minReplicaCount := func(scaledObject *unstructured.Unstructured) int64 {
+		value, _, err := unstructured.NestedInt64(scaledObject.Object, "spec", "minReplicaCount")
+		Expect(err).NotTo(HaveOccurred())
+		return value
+	}
+
+	It("pauses, overrides and restores the scaledobject scaling a deployment", func() {
+		reconciler := &WorkloadScheduleControllerReconciler{Client: k8sClient, Scheme: scheme.Scheme, IWorkloadScheduleHandler: workloadScheduleHandler.New()}
+
+		Expect(k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "consumer"}, Spec: apps.DeploymentSpec{
+			Replicas: pointer.Int32(2), Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: core.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}, Spec: core.PodSpec{Containers: []core.Container{{Name: "consumer", Image: "consumer"}}}},
+		}})).To(Succeed())
+		scaledObject := newScaledObject()
+		scaledObject.Object["spec"] = map[string]interface{}{"scaleTargetRef": map[string]interface{}{"name": "consumer"}, "minReplicaCount": int64(1), "maxReplicaCount": int64(10)}
+		Expect(k8sClient.Create(ctx, scaledObject)).To(Succeed())
+		Expect(k8sClient.Create(ctx, &workloadschedulerv1.Schedule{ObjectMeta: metav1.ObjectMeta{Name: "always"}, Spec: workloadschedulerv1.ScheduleSpec{
+			ScheduleUnits: []workloadschedulerv1.ScheduleUnit{{Start: workloadschedulerv1.TimeUnit{Date: "2000-01-01"}, End: workloadschedulerv1.TimeUnit{Date: "2999-12-31"}}},
+		}})).To(Succeed())
+		workloadSchedule := &workloadschedulerv1.WorkloadSchedule{ObjectMeta: metav1.ObjectMeta{Name: "consumer"}, Spec: workloadschedulerv1.WorkloadScheduleSpec{
+			Selector:  workloadschedulerv1.WorkloadSelector{Namespaces: []string{namespace}, Kinds: []string{"deployment"}, Names: []string{"consumer"}},
+			Schedules: []workloadschedulerv1.WorkloadScheduleUnit{{Schedule: "always", Desired: 0}},
+		}}
+		Expect(k8sClient.Create(ctx, workloadSchedule)).To(Succeed())
+
+		By("pausing the scaledobject when desired is 0")
+		Expect(reconciler.RunJob(ctx)).To(Succeed())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(scaledObject), scaledObject)).To(Succeed())
+		Expect(scaledObject.GetAnnotations()).To(HaveKeyWithValue("autoscaling.keda.sh/paused-replicas", "0"))
+		deployment := &apps.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "consumer"}, deployment)).To(Succeed())
+		Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+
+		By("resuming the scaledobject with desired as minReplicaCount")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workloadSchedule), workloadSchedule)).To(Succeed())
+		workloadSchedule.Spec.Schedules[0].Desired = 3
+		Expect(k8sClient.Update(ctx, workloadSchedule)).To(Succeed())
+		Expect(reconciler.RunJob(ctx)).To(Succeed())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(scaledObject), scaledObject)).To(Succeed())
+		Expect(scaledObject.GetAnnotations()).NotTo(HaveKey("autoscaling.keda.sh/paused-replicas"))
+		Expect(minReplicaCount(scaledObject)).To(Equal(int64(3)))
+
+		By("restoring the scaledobject once no schedule applies")
+		Expect(k8sClient.Delete(ctx, workloadSchedule)).To(Succeed())
+		Expect(reconciler.RunJob(ctx)).To(Succeed())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(scaledObject), scaledObject)).To(Succeed())
+		Expect(scaledObject.GetAnnotations()).NotTo(HaveKey("workload-scheduler.bennsimon.github.io/original-scaledobject-bounds"))
+		Expect(minReplicaCount(scaledObject)).To(Equal(int64(1)))
+	})
+})
@@ -13,6 +13,15 @@ const (
 	NamespacesOffLimits    = "NAMESPACES_OFF_LIMITS"
 	ReconciliationDuration = "RECONCILIATION_DURATION"
 	Debug                  = "DEBUG"
+	RestoreBaseline        = "RESTORE_BASELINE"
+	MinReplicas            = "MIN_REPLICAS"
+	MaxReplicas            = "MAX_REPLICAS"
+	MaxChangesPerRun       = "MAX_CHANGES_PER_RUN"
+	NamespaceBatchSize     = "NAMESPACE_BATCH_SIZE"
+	JitterWindow           = "JITTER_WINDOW"
+	WriteQPS               = "WRITE_QPS"
+	OperatorNamespace      = "OPERATOR_NAMESPACE"
+	DependencyTimeout      = "DEPENDENCY_TIMEOUT"
 )
 
 type Provider interface {
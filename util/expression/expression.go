@@ -0,0 +1,95 @@
+package expression
+
+import (
+	"fmt"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"k8s.io/apimachinery/pkg/runtime"
+	"math"
+)
+
+const (
+	// WorkloadVariable is the name under which the evaluated workload object is exposed to expressions.
+	WorkloadVariable = "workload"
+	// CurrentVariable is the name under which the current replicas of the workload are exposed to desired expressions.
+	CurrentVariable = "current"
+	// BaselineVariable is the name under which the baseline replicas of the workload are exposed to desired expressions.
+	BaselineVariable = "baseline"
+)
+
+func newEnv(opts ...cel.EnvOption) (*cel.Env, error) {
+	return cel.NewEnv(append([]cel.EnvOption{cel.Variable(WorkloadVariable, cel.DynType)}, opts...)...)
+}
+
+// CompileFilter parses and type-checks a boolean CEL expression evaluated against a workload.
+func CompileFilter(expr string) (cel.Program, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if outputType := ast.OutputType(); !outputType.IsAssignableType(cel.BoolType) {
+		return nil, fmt.Errorf("expression: %s must evaluate to bool, got %s", expr, outputType)
+	}
+	return env.Program(ast)
+}
+
+// EvaluateFilter evaluates a compiled filter against the workload, the workload is converted to its unstructured form.
+func EvaluateFilter(program cel.Program, workload runtime.Object) (bool, error) {
+	unstructuredWorkload, err := runtime.DefaultUnstructuredConverter.ToUnstructured(workload)
+	if err != nil {
+		return false, err
+	}
+	val, _, err := program.Eval(map[string]interface{}{WorkloadVariable: unstructuredWorkload})
+	if err != nil {
+		return false, err
+	}
+	if val.Type() != types.BoolType {
+		return false, fmt.Errorf("expression evaluated to %s, expected bool", val.Type().TypeName())
+	}
+	return val.Value().(bool), nil
+}
+
+// CompileDesired parses and type-checks an integer CEL expression computing the desired replicas of a workload.
+func CompileDesired(expr string) (cel.Program, error) {
+	env, err := newEnv(cel.Variable(CurrentVariable, cel.IntType), cel.Variable(BaselineVariable, cel.IntType))
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if outputType := ast.OutputType(); !outputType.IsAssignableType(cel.IntType) && !outputType.IsAssignableType(cel.UintType) {
+		return nil, fmt.Errorf("expression: %s must evaluate to int, got %s", expr, outputType)
+	}
+	return env.Program(ast)
+}
+
+// EvaluateDesired evaluates a compiled desired expression against the workload, its current and baseline replicas.
+func EvaluateDesired(program cel.Program, workload runtime.Object, current int32, baseline int32) (int32, error) {
+	unstructuredWorkload, err := runtime.DefaultUnstructuredConverter.ToUnstructured(workload)
+	if err != nil {
+		return 0, err
+	}
+	val, _, err := program.Eval(map[string]interface{}{WorkloadVariable: unstructuredWorkload, CurrentVariable: int64(current), BaselineVariable: int64(baseline)})
+	if err != nil {
+		return 0, err
+	}
+	var desired int64
+	switch val.Type() {
+	case types.IntType:
+		desired = val.Value().(int64)
+	case types.UintType:
+		desired = int64(val.Value().(uint64))
+	default:
+		return 0, fmt.Errorf("expression evaluated to %s, expected int", val.Type().TypeName())
+	}
+	if desired < 0 || desired > math.MaxInt32 {
+		return 0, fmt.Errorf("expression evaluated to %d, expected a replica count between 0 and %d", desired, math.MaxInt32)
+	}
+	return int32(desired), nil
+}
@@ -0,0 +1,123 @@
+package expression
+
+import (
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "should return error when expression cannot be parsed.", expr: "workload.spec.replicas >", wantErr: true},
+		{name: "should return error when expression references an undeclared variable.", expr: "deployment.spec.replicas > 2", wantErr: true},
+		{name: "should return error when expression does not evaluate to bool.", expr: "1 + 2", wantErr: true},
+		{name: "should compile a boolean expression.", expr: "workload.spec.replicas > 2", wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileFilter(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompileFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluateFilter(t *testing.T) {
+	deployment := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deploy", Namespace: "default", Labels: map[string]string{"tier": "backend"}},
+		Spec: apps.DeploymentSpec{Replicas: pointer.Int32(3), Template: core.PodTemplateSpec{Spec: core.PodSpec{Containers: []core.Container{
+			{Name: "app", Image: "registry.internal/app:1.0"},
+		}}}},
+	}
+	tests := []struct {
+		name    string
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "should match on replicas and image registry.", expr: "workload.spec.replicas > 2 && workload.spec.template.spec.containers.all(c, c.image.startsWith('registry.internal/'))", want: true},
+		{name: "should not match when condition is false.", expr: "workload.metadata.labels['tier'] == 'frontend'", want: false},
+		{name: "should match on missing fields with has().", expr: "!has(workload.spec.paused)", want: true},
+		{name: "should return error when expression does not evaluate to bool at runtime.", expr: "workload.metadata.name", wantErr: true},
+		{name: "should return error when field does not exist.", expr: "workload.spec.volumeClaimTemplates.size() == 0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := CompileFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileFilter() error = %v", err)
+			}
+			got, err := EvaluateFilter(program, deployment)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EvaluateFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateFilter() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileDesired(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "should return error when expression cannot be parsed.", expr: "current /", wantErr: true},
+		{name: "should return error when expression does not evaluate to int.", expr: "current > 2", wantErr: true},
+		{name: "should compile an integer expression.", expr: "baseline / 2", wantErr: false},
+		{name: "should compile an expression on workload fields.", expr: "int(workload.metadata.annotations['baseline']) / 2", wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileDesired(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompileDesired() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluateDesired(t *testing.T) {
+	deployment := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deploy", Namespace: "default", Labels: map[string]string{"tier": "gold"}, Annotations: map[string]string{"baseline": "8"}},
+		Spec:       apps.DeploymentSpec{Replicas: pointer.Int32(3)},
+	}
+	tests := []struct {
+		name    string
+		expr    string
+		want    int32
+		wantErr bool
+	}{
+		{name: "should compute from annotation.", expr: "int(workload.metadata.annotations['baseline']) / 2", want: 4},
+		{name: "should compute per label.", expr: "workload.metadata.labels['tier'] == 'gold' ? 5 : 1", want: 5},
+		{name: "should compute from current and baseline.", expr: "current + baseline", want: 13},
+		{name: "should return error when evaluating to a negative count.", expr: "current - baseline", wantErr: true},
+		{name: "should return error when evaluating to a non integer.", expr: "workload.metadata.name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := CompileDesired(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileDesired() error = %v", err)
+			}
+			got, err := EvaluateDesired(program, deployment, 3, 10)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EvaluateDesired() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateDesired() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,9 +10,68 @@ import (
 const (
 	DEPLOYMENT  = "deployment"
 	STATEFULSET = "statefulset"
+	CRONJOB     = "cronjob"
+	JOB         = "job"
+	DAEMONSET   = "daemonset"
+	KNATIVE     = "knativeservice"
+	QUOTA       = "resourcequota"
+	SERVICE     = "service"
 	ALL         = "*"
 )
 
+const (
+	AnnotationPrefix = "workload-scheduler.bennsimon.github.io/"
+	// SuspendedAnnotation marks workloads suspended by the operator, only those are resumed.
+	SuspendedAnnotation = AnnotationPrefix + "suspended"
+	// BaselineReplicasAnnotation records the replica count of a workload before the operator first changed it.
+	BaselineReplicasAnnotation = AnnotationPrefix + "baseline-replicas"
+	// MinReplicasAnnotation protects a workload from being scheduled below the replicas it holds.
+	MinReplicasAnnotation = AnnotationPrefix + "min-replicas"
+	// MaxReplicasAnnotation protects a workload from being scheduled above the replicas it holds.
+	MaxReplicasAnnotation = AnnotationPrefix + "max-replicas"
+	// OriginalNodeSelectorAnnotation records the node selector of a hibernated daemonset as json.
+	OriginalNodeSelectorAnnotation = AnnotationPrefix + "original-node-selector"
+	// HibernatedNodeSelectorKey is the node selector label no node is expected to carry, it keeps hibernated daemonsets off every node.
+	HibernatedNodeSelectorKey = AnnotationPrefix + "hibernated"
+	// OriginalHPABoundsAnnotation records the bounds of a horizontalpodautoscaler adjusted by a workload schedule as json.
+	OriginalHPABoundsAnnotation = AnnotationPrefix + "original-hpa-bounds"
+	// OriginalScaledObjectBoundsAnnotation records the replica bounds and pause annotation of a KEDA scaledobject adjusted by a workload schedule as json.
+	OriginalScaledObjectBoundsAnnotation = AnnotationPrefix + "original-scaledobject-bounds"
+	// OriginalResourcesAnnotation records the container requests and limits of a workload resized by a workload schedule as json.
+	OriginalResourcesAnnotation = AnnotationPrefix + "original-resources"
+	// RestartedOccurrenceAnnotation records the occurrence of the schedule a workload was last restarted for.
+	RestartedOccurrenceAnnotation = AnnotationPrefix + "restarted-occurrence"
+	// OriginalQuotaAnnotation records the hard limits of a resourcequota switched by a workload schedule as json.
+	OriginalQuotaAnnotation = AnnotationPrefix + "original-quota"
+	// OriginalServiceAnnotation records the fields and annotations of a LoadBalancer service hibernated by a workload schedule as json.
+	OriginalServiceAnnotation = AnnotationPrefix + "original-service"
+	// PatchAnnotation records the patch applied to an object by a workload schedule and its inverse as json.
+	PatchAnnotation = AnnotationPrefix + "patch"
+	// SnapshotRoundAnnotation records the round of volume snapshots a statefulset waits for before it is scaled to 0.
+	SnapshotRoundAnnotation = AnnotationPrefix + "snapshot-round"
+	// SnapshotStatefulSetLabel marks the volume snapshots taken by the operator with the statefulset they were taken for.
+	SnapshotStatefulSetLabel = AnnotationPrefix + "statefulset"
+	// SnapshotRoundLabel marks the volume snapshots taken by the operator with their round.
+	SnapshotRoundLabel = AnnotationPrefix + "snapshot-round"
+	// VerificationAnnotation records the scale up of a workload verified for ready replicas as json.
+	VerificationAnnotation = AnnotationPrefix + "verification"
+	// OriginalKnativeScaleAnnotation records the scale annotations of a knative service revision template adjusted by a workload schedule as json.
+	OriginalKnativeScaleAnnotation = AnnotationPrefix + "original-knative-scale"
+)
+
+// IsKindReference reports whether kind references a workload as Kind.version.group or Kind.group instead of a built-in kind.
+func IsKindReference(kind string) bool {
+	if !strings.Contains(kind, ".") {
+		return false
+	}
+	for _, segment := range strings.Split(kind, ".") {
+		if len(segment) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func ProcessScheduleTimeUnit(timeUnit workloadschedulerv1.TimeUnit, today time.Time) (time.Time, error) {
 	_format := time.DateTime
 	_time := timeUnit.Time
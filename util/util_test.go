@@ -42,3 +42,24 @@ func TestProcessScheduleTimeUnit(t *testing.T) {
 		})
 	}
 }
+
+func TestIsKindReference(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		want bool
+	}{
+		{name: "should return false for built-in kinds.", kind: DEPLOYMENT, want: false},
+		{name: "should return true for Kind.version.group references.", kind: "ReplicaSet.v1.apps", want: true},
+		{name: "should return true for Kind.group references.", kind: "Rollout.argoproj.io", want: true},
+		{name: "should return false when a segment is empty.", kind: "ReplicaSet..apps", want: false},
+		{name: "should return false when kind is missing.", kind: ".v1.apps", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsKindReference(tt.kind); got != tt.want {
+				t.Errorf("IsKindReference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}